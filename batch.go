@@ -0,0 +1,145 @@
+package sqlxx
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// DefaultBatchMaxParams is the placeholder limit of MySQL and PostgreSQL.
+	DefaultBatchMaxParams = 65535
+	// DefaultBatchMaxBytes is the default max_allowed_packet of MySQL.
+	DefaultBatchMaxBytes = 4 << 20
+
+	CmdBatchInsert = "BATCH"
+)
+
+type BatchOption struct {
+	// Columns to insert. All the columns of the struct are inserted if empty.
+	Columns []string
+	// MaxParams is the maximum number of placeholders in a statement.
+	MaxParams int
+	// MaxBytes is the maximum size of a statement including the arguments,
+	// which should not exceed max_allowed_packet.
+	MaxBytes int
+}
+
+// BatchInsert inserts rows, a slice of db-tagged structs or pointers to
+// them, into table with multi-row INSERT statements. The rows are split into
+// chunks within opts.MaxParams and opts.MaxBytes, which are run in order in
+// the transaction in ctx if any; otherwise the chunks before a failing one
+// stay inserted. A summary of each chunk is logged as CmdBatchInsert, with
+// the statement for a single row and the number of rows affected. It
+// returns the total number of rows affected.
+func (db *DB) BatchInsert(ctx context.Context, table string, rows interface{}, opts *BatchOption) (int64, error) {
+	if opts == nil {
+		opts = &BatchOption{}
+	}
+	maxParams, maxBytes := opts.MaxParams, opts.MaxBytes
+	if maxParams <= 0 {
+		maxParams = DefaultBatchMaxParams
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultBatchMaxBytes
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(rows))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return 0, xerrors.Errorf("sqlxx: want slice, got %T", rows)
+	}
+	cols, err := db.columns(v.Type().Elem())
+	if err != nil {
+		return 0, err
+	}
+	if cols, err = selectColumns(cols, opts.Columns); err != nil {
+		return 0, err
+	}
+	if len(cols) > maxParams {
+		return 0, xerrors.Errorf("sqlxx: %d columns exceed %d params", len(cols), maxParams)
+	}
+
+	head := "INSERT INTO " + table + " (" + strings.Join(columnNames(cols), ", ") + ") VALUES "
+	tuple := "(?" + strings.Repeat(", ?", len(cols)-1) + ")"
+
+	var (
+		total int64
+		n     int
+		args  []interface{}
+		size  = len(head)
+	)
+	flush := func() error {
+		if n == 0 {
+			return nil
+		}
+		query := head + tuple + strings.Repeat(", "+tuple, n-1)
+		affected, err := db.execChunk(ctx, head+tuple, db.dbx.Rebind(query), args)
+		total += affected
+		n, args, size = 0, args[:0], len(head)
+		return err
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if elem.Kind() == reflect.Ptr && elem.IsNil() {
+			return total, xerrors.Errorf("sqlxx: nil row at %d", i)
+		}
+		rowArgs := columnValues(nil, elem, cols)
+		rowSize := len(tuple) + 2
+		for _, arg := range rowArgs {
+			rowSize += argSize(arg)
+		}
+
+		if n > 0 && ((n+1)*len(cols) > maxParams || size+rowSize > maxBytes) {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+		n++
+		args = append(args, rowArgs...)
+		size += rowSize
+	}
+	if err := flush(); err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+func (db *DB) execChunk(ctx context.Context, summary, query string, args []interface{}) (int64, error) {
+	var affected int64
+	info := &QueryInfo{Command: CmdBatchInsert, Query: summary}
+	err := db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) error {
+		res, err := db.cached(db.build(ctx), info).ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		affected, err = res.RowsAffected()
+		info.Rows = int(affected)
+		return err
+	})
+	return affected, err
+}
+
+// argSize estimates the size of arg in a statement.
+func argSize(arg interface{}) int {
+	switch a := arg.(type) {
+	case nil:
+		return 4
+	case string:
+		return 2*len(a) + 2 // escaped and quoted
+	case []byte:
+		return 2*len(a) + 2
+	case time.Time:
+		return 28
+	}
+	if v := reflect.ValueOf(arg); v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 4
+		}
+		return argSize(v.Elem().Interface())
+	}
+	return 24
+}
@@ -0,0 +1,114 @@
+package sqlxx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBatchInsert(t *testing.T) {
+	var users []User
+	for i := 0; i < 9; i++ {
+		users = append(users, newUser(fmt.Sprintf("batch%d@example.com", i), testPassword))
+	}
+
+	tests := []struct {
+		name       string
+		rows       interface{}
+		opts       *BatchOption
+		wantChunks int
+	}{
+		{"one chunk", users, &BatchOption{Columns: []string{"email", "password"}}, 1},
+		{"max params", users, &BatchOption{Columns: []string{"email", "password"}, MaxParams: 4}, 5},
+		{"max bytes", users, &BatchOption{Columns: []string{"email", "password"}, MaxBytes: 100}, 9},
+		{"pointers", []*User{&users[0], &users[1], &users[2]}, &BatchOption{Columns: []string{"password", "email"}, MaxParams: 5}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			db := New(dbx, NewLogger(&buf), nil)
+			errRollback := errors.New("rollback")
+
+			err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+				n, err := db.BatchInsert(ctx, "user", tt.rows, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var count int64
+				if err := db.Get(ctx, &count, "SELECT COUNT(*) FROM user WHERE email LIKE 'batch%';"); err != nil {
+					t.Fatal(err)
+				}
+				if n != count {
+					t.Errorf("want %d rows affected, got %d", count, n)
+				}
+				return errRollback
+			})
+			if err != errRollback {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			var chunks []string
+			for _, l := range lines {
+				if strings.Contains(l, "["+CmdBatchInsert+"]") {
+					chunks = append(chunks, l)
+				}
+			}
+			if len(chunks) != tt.wantChunks {
+				t.Fatalf("want %d chunks, got %q", tt.wantChunks, chunks)
+			}
+			want := "INSERT INTO user (" + strings.Join(tt.opts.Columns, ", ") + ") VALUES (?, ?) []"
+			if !strings.Contains(chunks[0], want) {
+				t.Errorf("want summary %q, got %q", want, chunks[0])
+			}
+		})
+	}
+}
+
+func TestBatchInsertError(t *testing.T) {
+	ctx := context.Background()
+	u := newUser("batch-error@example.com", testPassword)
+	errRollback := errors.New("rollback")
+
+	tests := []struct {
+		name string
+		rows interface{}
+		opts *BatchOption
+	}{
+		{"not slice", u, nil},
+		{"not struct", []int{1}, nil},
+		{"unknown column", []User{u}, &BatchOption{Columns: []string{"name"}}},
+		{"too many columns", []User{u}, &BatchOption{MaxParams: 2}},
+		{"nil row", []*User{nil}, nil},
+	}
+	for _, tt := range tests {
+		if _, err := db.BatchInsert(ctx, "user", tt.rows, tt.opts); err == nil {
+			t.Errorf("%s: want non-nil error", tt.name)
+		}
+	}
+
+	err, _ := db.RunInTx(ctx, func(ctx context.Context) error {
+		n, err := db.BatchInsert(ctx, "user", []User{}, nil)
+		if n != 0 || err != nil {
+			t.Errorf("empty: want (0, nil), got (%d, %v)", n, err)
+		}
+
+		rows := []User{u, newUser("batch-error2@example.com", testPassword), u}
+		n, err = db.BatchInsert(ctx, "user", rows, &BatchOption{Columns: []string{"email", "password"}, MaxParams: 4})
+		if err == nil {
+			t.Error("duplicate: want non-nil error")
+		}
+		if n != 2 {
+			t.Errorf("duplicate: want 2 rows inserted before the error, got %d", n)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,515 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Builder builds a query with ? placeholders and its args.
+type Builder interface {
+	ToSQL() (string, []interface{}, error)
+}
+
+// Build returns the query of b with the placeholders rebound for the driver
+// of the underlying *sqlx.DB.
+func (db *DB) Build(b Builder) (string, []interface{}, error) {
+	query, args, err := b.ToSQL()
+	if err != nil {
+		return "", nil, err
+	}
+	return db.dbx.Rebind(query), args, nil
+}
+
+// Cond is a condition of Where and Having.
+type Cond interface {
+	Builder
+}
+
+// Expr is a raw SQL condition or expression with ? placeholders.
+type Expr struct {
+	SQL  string
+	Args []interface{}
+}
+
+func (e Expr) ToSQL() (string, []interface{}, error) {
+	return e.SQL, e.Args, nil
+}
+
+// Eq is col = value for each entry, joined with AND. A nil value becomes
+// IS NULL, and a slice becomes IN (...).
+type Eq map[string]interface{}
+
+// NotEq is the negation of Eq for each entry: <>, IS NOT NULL or NOT IN.
+type NotEq map[string]interface{}
+
+// Lt, LtOrEq, Gt and GtOrEq compare each column with the value, joined with AND.
+type (
+	Lt     map[string]interface{}
+	LtOrEq map[string]interface{}
+	Gt     map[string]interface{}
+	GtOrEq map[string]interface{}
+)
+
+// And and Or join the conditions in parentheses.
+type (
+	And []Cond
+	Or  []Cond
+)
+
+func (eq Eq) ToSQL() (string, []interface{}, error)    { return eqSQL(eq, false) }
+func (eq NotEq) ToSQL() (string, []interface{}, error) { return eqSQL(eq, true) }
+func (m Lt) ToSQL() (string, []interface{}, error)     { return compSQL(m, "<") }
+func (m LtOrEq) ToSQL() (string, []interface{}, error) { return compSQL(m, "<=") }
+func (m Gt) ToSQL() (string, []interface{}, error)     { return compSQL(m, ">") }
+func (m GtOrEq) ToSQL() (string, []interface{}, error) { return compSQL(m, ">=") }
+func (a And) ToSQL() (string, []interface{}, error)    { return joinConds(a, " AND ", "(1=1)") }
+func (o Or) ToSQL() (string, []interface{}, error)     { return joinConds(o, " OR ", "(1=0)") }
+
+func eqSQL(m map[string]interface{}, not bool) (string, []interface{}, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, col := range sortedKeys(m) {
+		v := m[col]
+		switch {
+		case v == nil:
+			if not {
+				conds = append(conds, col+" IS NOT NULL")
+			} else {
+				conds = append(conds, col+" IS NULL")
+			}
+		case isListArg(v):
+			rv := reflect.ValueOf(v)
+			if rv.Len() == 0 {
+				if not {
+					conds = append(conds, "(1=1)")
+				} else {
+					conds = append(conds, "(1=0)")
+				}
+				continue
+			}
+			op := " IN ("
+			if not {
+				op = " NOT IN ("
+			}
+			conds = append(conds, col+op+"?"+strings.Repeat(", ?", rv.Len()-1)+")")
+			for i := 0; i < rv.Len(); i++ {
+				args = append(args, rv.Index(i).Interface())
+			}
+		default:
+			if not {
+				conds = append(conds, col+" <> ?")
+			} else {
+				conds = append(conds, col+" = ?")
+			}
+			args = append(args, v)
+		}
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+func compSQL(m map[string]interface{}, op string) (string, []interface{}, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, col := range sortedKeys(m) {
+		if isListArg(m[col]) {
+			return "", nil, xerrors.Errorf("sqlxx: cannot use %s with a list: %s", op, col)
+		}
+		conds = append(conds, col+" "+op+" ?")
+		args = append(args, m[col])
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+func joinConds(conds []Cond, sep, empty string) (string, []interface{}, error) {
+	if len(conds) == 0 {
+		return empty, nil, nil
+	}
+	var (
+		parts []string
+		args  []interface{}
+	)
+	for _, c := range conds {
+		s, a, err := c.ToSQL()
+		if err != nil {
+			return "", nil, err
+		}
+		if s == "" {
+			continue
+		}
+		if len(conds) > 1 && needsParens(c) {
+			s = "(" + s + ")"
+		}
+		parts = append(parts, s)
+		args = append(args, a...)
+	}
+	if len(parts) == 0 {
+		return empty, nil, nil
+	}
+	return "(" + strings.Join(parts, sep) + ")", args, nil
+}
+
+// needsParens reports whether c must be parenthesized when joined with
+// other conditions: the built-in conditions are comparisons joined with AND,
+// or already parenthesized, but an Expr may contain OR.
+func needsParens(c Cond) bool {
+	switch c.(type) {
+	case Eq, NotEq, Lt, LtOrEq, Gt, GtOrEq, And, Or:
+		return false
+	}
+	return true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isListArg reports whether v is a slice or an array to expand into IN,
+// excluding []byte and driver.Valuer.
+func isListArg(v interface{}) bool {
+	if _, ok := v.(driver.Valuer); ok {
+		return false
+	}
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return false
+	}
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8
+}
+
+// whereClause is shared by the builders which have WHERE.
+type whereClause []Cond
+
+func (w whereClause) add(pred interface{}, args []interface{}) whereClause {
+	switch p := pred.(type) {
+	case Cond:
+		return append(w, p)
+	case string:
+		return append(w, Expr{p, args})
+	}
+	return append(w, errCond{xerrors.Errorf("sqlxx: unsupported condition: %T", pred)})
+}
+
+func (w whereClause) write(b *strings.Builder, keyword string, args []interface{}) ([]interface{}, error) {
+	if len(w) == 0 {
+		return args, nil
+	}
+	parts := make([]string, 0, len(w))
+	for _, c := range w {
+		s, a, err := c.ToSQL()
+		if err != nil {
+			return nil, err
+		}
+		if s == "" {
+			continue
+		}
+		if len(w) > 1 && needsParens(c) {
+			s = "(" + s + ")"
+		}
+		parts = append(parts, s)
+		args = append(args, a...)
+	}
+	if len(parts) > 0 {
+		b.WriteString(" " + keyword + " " + strings.Join(parts, " AND "))
+	}
+	return args, nil
+}
+
+type errCond struct{ err error }
+
+func (e errCond) ToSQL() (string, []interface{}, error) { return "", nil, e.err }
+
+// SelectBuilder builds a SELECT statement. Use Select to create one.
+type SelectBuilder struct {
+	columns []string
+	from    string
+	joins   []Expr
+	where   whereClause
+	groupBy []string
+	having  whereClause
+	orderBy []string
+	limit   *uint64
+	offset  *uint64
+}
+
+// Select starts a SELECT statement of columns.
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns}
+}
+
+func (b *SelectBuilder) Columns(columns ...string) *SelectBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from = table
+	return b
+}
+
+// Join adds a join clause, e.g. Join("JOIN session ON session.user_id = user.id").
+func (b *SelectBuilder) Join(join string, args ...interface{}) *SelectBuilder {
+	b.joins = append(b.joins, Expr{join, args})
+	return b
+}
+
+// Where adds a condition, a Cond or a string with ? placeholders for args.
+// Conditions are joined with AND.
+func (b *SelectBuilder) Where(pred interface{}, args ...interface{}) *SelectBuilder {
+	b.where = b.where.add(pred, args)
+	return b
+}
+
+func (b *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, columns...)
+	return b
+}
+
+func (b *SelectBuilder) Having(pred interface{}, args ...interface{}) *SelectBuilder {
+	b.having = b.having.add(pred, args)
+	return b
+}
+
+func (b *SelectBuilder) OrderBy(orders ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, orders...)
+	return b
+}
+
+func (b *SelectBuilder) Limit(n uint64) *SelectBuilder {
+	b.limit = &n
+	return b
+}
+
+func (b *SelectBuilder) Offset(n uint64) *SelectBuilder {
+	b.offset = &n
+	return b
+}
+
+func (b *SelectBuilder) ToSQL() (string, []interface{}, error) {
+	if len(b.columns) == 0 {
+		return "", nil, xerrors.New("sqlxx: select requires columns")
+	}
+
+	var (
+		sb   strings.Builder
+		args []interface{}
+		err  error
+	)
+	sb.WriteString("SELECT " + strings.Join(b.columns, ", "))
+	if b.from != "" {
+		sb.WriteString(" FROM " + b.from)
+	}
+	for _, j := range b.joins {
+		sb.WriteString(" " + j.SQL)
+		args = append(args, j.Args...)
+	}
+	if args, err = b.where.write(&sb, "WHERE", args); err != nil {
+		return "", nil, err
+	}
+	if len(b.groupBy) > 0 {
+		sb.WriteString(" GROUP BY " + strings.Join(b.groupBy, ", "))
+	}
+	if args, err = b.having.write(&sb, "HAVING", args); err != nil {
+		return "", nil, err
+	}
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" ORDER BY " + strings.Join(b.orderBy, ", "))
+	}
+	if b.limit != nil {
+		sb.WriteString(" LIMIT " + strconv.FormatUint(*b.limit, 10))
+	}
+	if b.offset != nil {
+		sb.WriteString(" OFFSET " + strconv.FormatUint(*b.offset, 10))
+	}
+	return sb.String(), args, nil
+}
+
+// Get runs the statement with db.Get.
+func (b *SelectBuilder) Get(ctx context.Context, db *DB, dest interface{}) error {
+	query, args, err := db.Build(b)
+	if err != nil {
+		return err
+	}
+	return db.Get(ctx, dest, query, args...)
+}
+
+// Select runs the statement with db.Select.
+func (b *SelectBuilder) Select(ctx context.Context, db *DB, dest interface{}) error {
+	query, args, err := db.Build(b)
+	if err != nil {
+		return err
+	}
+	return db.Select(ctx, dest, query, args...)
+}
+
+// Query runs the statement with db.Query.
+func (b *SelectBuilder) Query(ctx context.Context, db *DB) (*Rows, error) {
+	query, args, err := db.Build(b)
+	if err != nil {
+		return nil, err
+	}
+	return db.Query(ctx, query, args...)
+}
+
+// InsertBuilder builds an INSERT statement. Use Insert to create one.
+type InsertBuilder struct {
+	table   string
+	columns []string
+	values  [][]interface{}
+}
+
+// Insert starts an INSERT statement into table.
+func Insert(table string) *InsertBuilder {
+	return &InsertBuilder{table: table}
+}
+
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Values adds a row. Call it multiple times to insert multiple rows.
+func (b *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
+	b.values = append(b.values, values)
+	return b
+}
+
+func (b *InsertBuilder) ToSQL() (string, []interface{}, error) {
+	if len(b.columns) == 0 || len(b.values) == 0 {
+		return "", nil, xerrors.New("sqlxx: insert requires columns and values")
+	}
+
+	tuple := "(?" + strings.Repeat(", ?", len(b.columns)-1) + ")"
+	tuples := make([]string, len(b.values))
+	args := make([]interface{}, 0, len(b.columns)*len(b.values))
+	for i, row := range b.values {
+		if len(row) != len(b.columns) {
+			return "", nil, xerrors.Errorf("sqlxx: %d values for %d columns", len(row), len(b.columns))
+		}
+		tuples[i] = tuple
+		args = append(args, row...)
+	}
+	query := "INSERT INTO " + b.table + " (" + strings.Join(b.columns, ", ") + ") VALUES " + strings.Join(tuples, ", ")
+	return query, args, nil
+}
+
+// Exec runs the statement with db.Exec.
+func (b *InsertBuilder) Exec(ctx context.Context, db *DB) (sql.Result, error) {
+	return execBuilder(ctx, db, b)
+}
+
+// UpdateBuilder builds an UPDATE statement. Use Update to create one.
+type UpdateBuilder struct {
+	table string
+	sets  []Expr
+	where whereClause
+}
+
+// Update starts an UPDATE statement of table.
+func Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{table: table}
+}
+
+// Set adds column = value. value may be an Expr, e.g. Expr{"count + ?", []interface{}{1}}.
+func (b *UpdateBuilder) Set(column string, value interface{}) *UpdateBuilder {
+	if e, ok := value.(Expr); ok {
+		b.sets = append(b.sets, Expr{column + " = " + e.SQL, e.Args})
+	} else {
+		b.sets = append(b.sets, Expr{column + " = ?", []interface{}{value}})
+	}
+	return b
+}
+
+// SetMap adds column = value for each entry in column order.
+func (b *UpdateBuilder) SetMap(m map[string]interface{}) *UpdateBuilder {
+	for _, col := range sortedKeys(m) {
+		b.Set(col, m[col])
+	}
+	return b
+}
+
+func (b *UpdateBuilder) Where(pred interface{}, args ...interface{}) *UpdateBuilder {
+	b.where = b.where.add(pred, args)
+	return b
+}
+
+func (b *UpdateBuilder) ToSQL() (string, []interface{}, error) {
+	if len(b.sets) == 0 {
+		return "", nil, xerrors.New("sqlxx: update requires set")
+	}
+
+	var (
+		sb   strings.Builder
+		args []interface{}
+		sets = make([]string, len(b.sets))
+	)
+	for i, s := range b.sets {
+		sets[i] = s.SQL
+		args = append(args, s.Args...)
+	}
+	sb.WriteString("UPDATE " + b.table + " SET " + strings.Join(sets, ", "))
+	args, err := b.where.write(&sb, "WHERE", args)
+	if err != nil {
+		return "", nil, err
+	}
+	return sb.String(), args, nil
+}
+
+// Exec runs the statement with db.Exec.
+func (b *UpdateBuilder) Exec(ctx context.Context, db *DB) (sql.Result, error) {
+	return execBuilder(ctx, db, b)
+}
+
+// DeleteBuilder builds a DELETE statement. Use Delete to create one.
+type DeleteBuilder struct {
+	table string
+	where whereClause
+}
+
+// Delete starts a DELETE statement of table.
+func Delete(table string) *DeleteBuilder {
+	return &DeleteBuilder{table: table}
+}
+
+func (b *DeleteBuilder) Where(pred interface{}, args ...interface{}) *DeleteBuilder {
+	b.where = b.where.add(pred, args)
+	return b
+}
+
+func (b *DeleteBuilder) ToSQL() (string, []interface{}, error) {
+	var sb strings.Builder
+	sb.WriteString("DELETE FROM " + b.table)
+	args, err := b.where.write(&sb, "WHERE", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return sb.String(), args, nil
+}
+
+// Exec runs the statement with db.Exec.
+func (b *DeleteBuilder) Exec(ctx context.Context, db *DB) (sql.Result, error) {
+	return execBuilder(ctx, db, b)
+}
+
+func execBuilder(ctx context.Context, db *DB, b Builder) (sql.Result, error) {
+	query, args, err := db.Build(b)
+	if err != nil {
+		return nil, err
+	}
+	return db.Exec(ctx, query, args...)
+}
@@ -0,0 +1,184 @@
+package sqlxx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuilderToSQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		b         Builder
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			"select",
+			Select("id", "email").From("user").Where(Eq{"email": "a@example.com"}).OrderBy("id DESC").Limit(10).Offset(20),
+			"SELECT id, email FROM user WHERE email = ? ORDER BY id DESC LIMIT 10 OFFSET 20",
+			[]interface{}{"a@example.com"},
+		},
+		{
+			"eq",
+			Select("id").From("user").Where(Eq{"id": []int{1, 2}, "email": nil, "password": []byte("p")}),
+			"SELECT id FROM user WHERE email IS NULL AND id IN (?, ?) AND password = ?",
+			[]interface{}{1, 2, []byte("p")},
+		},
+		{
+			"not eq",
+			Select("id").From("user").Where(NotEq{"id": []int{1}, "email": nil, "password": "p"}),
+			"SELECT id FROM user WHERE email IS NOT NULL AND id NOT IN (?) AND password <> ?",
+			[]interface{}{1, "p"},
+		},
+		{
+			"empty in",
+			Select("id").From("user").Where(Eq{"id": []int{}}),
+			"SELECT id FROM user WHERE (1=0)",
+			nil,
+		},
+		{
+			"comparison",
+			Select("id").From("user").Where(Gt{"id": 1}).Where(LtOrEq{"id": 10}).Where("email LIKE ?", "%@example.com"),
+			"SELECT id FROM user WHERE id > ? AND id <= ? AND (email LIKE ?)",
+			[]interface{}{1, 10, "%@example.com"},
+		},
+		{
+			"and or",
+			Select("id").From("user").Where(Or{Eq{"id": 1}, And{Lt{"id": 10}, GtOrEq{"id": 5}}}),
+			"SELECT id FROM user WHERE (id = ? OR (id < ? AND id >= ?))",
+			[]interface{}{1, 10, 5},
+		},
+		{
+			"or expr",
+			Select("*").From("t").Where("a = ? OR b = ?", 1, 2).Where(Eq{"c": 3}),
+			"SELECT * FROM t WHERE (a = ? OR b = ?) AND c = ?",
+			[]interface{}{1, 2, 3},
+		},
+		{
+			"or expr in and",
+			Select("*").From("t").Where(And{Expr{"a = ? OR b = ?", []interface{}{1, 2}}, Eq{"c": 3}}),
+			"SELECT * FROM t WHERE ((a = ? OR b = ?) AND c = ?)",
+			[]interface{}{1, 2, 3},
+		},
+		{
+			"single expr",
+			Select("*").From("t").Where("a = ? OR b = ?", 1, 2),
+			"SELECT * FROM t WHERE a = ? OR b = ?",
+			[]interface{}{1, 2},
+		},
+		{
+			"join group having",
+			Select("user.id", "COUNT(*)").From("user").Join("JOIN session ON session.user_id = user.id AND session.expire_at > ?", 100).
+				GroupBy("user.id").Having("COUNT(*) > ?", 1),
+			"SELECT user.id, COUNT(*) FROM user JOIN session ON session.user_id = user.id AND session.expire_at > ? GROUP BY user.id HAVING COUNT(*) > ?",
+			[]interface{}{100, 1},
+		},
+		{
+			"insert",
+			Insert("user").Columns("email", "password").Values("a", "p").Values("b", "q"),
+			"INSERT INTO user (email, password) VALUES (?, ?), (?, ?)",
+			[]interface{}{"a", "p", "b", "q"},
+		},
+		{
+			"update",
+			Update("user").Set("password", "p").Set("id", Expr{"id + ?", []interface{}{1}}).Where(Eq{"email": "a"}),
+			"UPDATE user SET password = ?, id = id + ? WHERE email = ?",
+			[]interface{}{"p", 1, "a"},
+		},
+		{
+			"delete",
+			Delete("user").Where(Eq{"email": "a"}),
+			"DELETE FROM user WHERE email = ?",
+			[]interface{}{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := tt.b.ToSQL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("want %q, got %q", tt.wantQuery, query)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("want %v, got %v", tt.wantArgs, args)
+			}
+		})
+	}
+}
+
+func TestBuilderToSQLError(t *testing.T) {
+	for name, b := range map[string]Builder{
+		"no columns":      Select().From("user"),
+		"list comparison": Select("id").From("user").Where(Lt{"id": []int{1}}),
+		"bad condition":   Select("id").From("user").Where(1),
+		"no values":       Insert("user").Columns("email"),
+		"value count":     Insert("user").Columns("email").Values("a", "b"),
+		"no set":          Update("user"),
+	} {
+		if _, _, err := b.ToSQL(); err == nil {
+			t.Errorf("%s: want non-nil error", name)
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil)
+	errRollback := errors.New("rollback")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		if _, err := Insert("user").Columns("email", "password").
+			Values("builder1@example.com", testPassword).
+			Values("builder2@example.com", testPassword).Exec(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+
+		var u User
+		if err := Select("id", "email", "password").From("user").Where(Eq{"email": "builder1@example.com"}).Get(ctx, db, &u); err != nil {
+			t.Fatal(err)
+		}
+		if u.Email != "builder1@example.com" {
+			t.Errorf("want builder1@example.com, got %v", u.Email)
+		}
+
+		if _, err := Update("user").Set("password", "updated").Where(Eq{"id": u.ID}).Exec(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Delete("user").Where(Eq{"email": "builder2@example.com"}).Exec(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+
+		var users []User
+		q := Select("id", "email", "password").From("user").Where("email IN (?, ?)", "builder1@example.com", "builder2@example.com").OrderBy("id")
+		if err := q.Select(ctx, db, &users); err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 1 || users[0].Password != "updated" {
+			t.Errorf("want 1 updated user, got %v", users)
+		}
+
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"INSERT INTO user (email, password) VALUES (?, ?), (?, ?) [builder1@example.com, " + testPassword + ", builder2@example.com, ",
+		"SELECT id, email, password FROM user WHERE email = ? [builder1@example.com]",
+		"UPDATE user SET password = ? WHERE id = ? [updated, ",
+		"DELETE FROM user WHERE email = ? [builder2@example.com]",
+		"SELECT id, email, password FROM user WHERE email IN (?, ?) ORDER BY id [builder1@example.com, builder2@example.com]",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %q in the log, got %q", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package sqlxx
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx/reflectx"
+	"golang.org/x/xerrors"
+)
+
+// column is a column mapped from a field of a db-tagged struct.
+type column struct {
+	name  string
+	index []int
+	opts  map[string]string
+}
+
+var (
+	valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+// columns returns the columns of struct type t mapped by the mapper of the
+// underlying *sqlx.DB: the fields of t and of its embedded structs. Fields
+// of nested structs are skipped, unless the struct is a value itself, e.g.
+// time.Time or sql.NullString.
+func (db *DB) columns(t reflect.Type) ([]column, error) {
+	t = reflectx.Deref(t)
+	if t.Kind() != reflect.Struct {
+		return nil, xerrors.Errorf("sqlxx: want struct, got %s", t)
+	}
+
+	var cols []column
+	for _, fi := range db.dbx.Mapper.TypeMap(t).Index {
+		if fi.Embedded || fi.Name == "" || strings.Contains(fi.Path, ".") {
+			continue
+		}
+		if ft := reflectx.Deref(fi.Field.Type); ft.Kind() == reflect.Struct && !isValueType(fi.Field.Type) {
+			continue
+		}
+		cols = append(cols, column{name: fi.Path, index: fi.Index, opts: fi.Options})
+	}
+	if len(cols) == 0 {
+		return nil, xerrors.Errorf("sqlxx: no columns in %s", t)
+	}
+	return cols, nil
+}
+
+func isValueType(t reflect.Type) bool {
+	return t.Implements(valuerType) || reflect.PtrTo(t).Implements(valuerType) || reflectx.Deref(t) == timeType
+}
+
+// selectColumns returns the columns of cols named in names, in the order of
+// names. All of cols are returned if names is empty.
+func selectColumns(cols []column, names []string) ([]column, error) {
+	if len(names) == 0 {
+		return cols, nil
+	}
+	selected := make([]column, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, c := range cols {
+			if c.name == name {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, xerrors.Errorf("sqlxx: unknown column: %s", name)
+		}
+	}
+	return selected, nil
+}
+
+// columnValues appends the values of cols in struct v to args.
+func columnValues(args []interface{}, v reflect.Value, cols []column) []interface{} {
+	v = reflect.Indirect(v)
+	for _, c := range cols {
+		args = append(args, reflectx.FieldByIndexesReadOnly(v, c.index).Interface())
+	}
+	return args
+}
+
+func columnNames(cols []column) []string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+	}
+	return names
+}
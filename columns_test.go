@@ -0,0 +1,68 @@
+package sqlxx
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestColumns(t *testing.T) {
+	type Base struct {
+		ID        int64     `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	type Row struct {
+		Base
+		Name    string         `db:"name"`
+		Note    sql.NullString `db:"note"`
+		User    User           `db:"user"`
+		Ignored string         `db:"-"`
+		Count   *int           `db:"count"`
+		private string
+	}
+
+	cols, err := db.columns(reflect.TypeOf(&Row{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"name", "note", "count", "id", "created_at"}
+	if got := columnNames(cols); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	n := 3
+	r := Row{Base: Base{ID: 1}, Name: "a", Count: &n}
+	args := columnValues(nil, reflect.ValueOf(&r), cols)
+	if got, want := len(args), 5; got != want {
+		t.Fatalf("want %d args, got %d", want, got)
+	}
+	if args[0] != "a" || args[2] != &n || args[3] != int64(1) {
+		t.Errorf("wrong args: %v", args)
+	}
+
+	if _, err := db.columns(reflect.TypeOf(1)); err == nil {
+		t.Error("want non-nil error")
+	}
+	if _, err := db.columns(reflect.TypeOf(struct{ private int }{})); err == nil {
+		t.Error("want non-nil error")
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	cols := []column{{name: "a"}, {name: "b"}, {name: "c"}}
+
+	got, err := selectColumns(cols, []string{"c", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c", "a"}; !reflect.DeepEqual(columnNames(got), want) {
+		t.Errorf("want %v, got %v", want, columnNames(got))
+	}
+	if got, _ := selectColumns(cols, nil); len(got) != 3 {
+		t.Errorf("want all columns, got %v", got)
+	}
+	if _, err := selectColumns(cols, []string{"d"}); err == nil {
+		t.Error("want non-nil error")
+	}
+}
@@ -0,0 +1,215 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	"golang.org/x/xerrors"
+)
+
+// ErrNoPK is returned by the CRUD helpers for a struct without a field
+// tagged with the pk option, e.g. `db:"id,pk"`.
+var ErrNoPK = xerrors.New("sqlxx: no primary key")
+
+// Insert inserts row, a db-tagged struct, into table. A single integer
+// primary key (`db:"id,pk"`) holding zero is treated as auto-increment: it
+// is omitted from the statement, and populated if row is a pointer, with
+// INSERT ... RETURNING for PostgreSQL, or else from LastInsertId. It is
+// left zero if the driver does not support LastInsertId. It runs as
+// NamedExec.
+func (db *DB) Insert(ctx context.Context, table string, row interface{}) (sql.Result, error) {
+	cols, err := db.columns(reflect.TypeOf(row))
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(row))
+	auto := autoIncrementPK(v, cols)
+	names := make([]string, 0, len(cols))
+	for _, c := range cols {
+		if auto == nil || c.name != auto.name {
+			names = append(names, c.name)
+		}
+	}
+
+	query := insertQuery(db.dbx.DriverName(), table, names, auto)
+	if auto == nil {
+		return db.NamedExec(ctx, query, row)
+	}
+
+	var (
+		res sql.Result
+		id  int64
+	)
+	if isPostgres(db.dbx.DriverName()) {
+		if err := db.insertReturning(ctx, query, row, &id); err != nil {
+			return nil, err
+		}
+		res = insertResult(id)
+	} else {
+		if res, err = db.NamedExec(ctx, query, row); err != nil {
+			return res, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return res, nil
+		}
+	}
+
+	if v.CanAddr() {
+		f := reflectx.FieldByIndexes(v, auto.index)
+		if f.Kind() >= reflect.Uint && f.Kind() <= reflect.Uintptr {
+			f.SetUint(uint64(id))
+		} else {
+			f.SetInt(id)
+		}
+	}
+	return res, nil
+}
+
+// insertQuery returns the named INSERT of names, returning the
+// auto-increment primary key for PostgreSQL. auto may be nil.
+func insertQuery(driverName, table string, names []string, auto *column) string {
+	query := "INSERT INTO " + table + " (" + strings.Join(names, ", ") + ") VALUES (:" + strings.Join(names, ", :") + ")"
+	if auto != nil && isPostgres(driverName) {
+		query += " RETURNING " + auto.name
+	}
+	return query
+}
+
+// insertReturning runs the named INSERT ... RETURNING query on the primary,
+// and scans the returned value into dest.
+func (db *DB) insertReturning(ctx context.Context, query string, arg interface{}, dest interface{}) error {
+	_, args, _ := sqlx.BindNamed(sqlx.NAMED, query, arg)
+	info := &QueryInfo{Command: CmdNamedExec, Query: query, Args: args, named: true, arg: arg}
+	return db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) error {
+		query, args, err := db.bindNamed(info)
+		if err != nil {
+			return err
+		}
+		if err := db.cached(db.build(ctx), info).GetContext(ctx, dest, query, args...); err != nil {
+			return err
+		}
+		info.Rows = 1
+		return nil
+	})
+}
+
+// insertResult is the sql.Result of a row inserted by insertReturning.
+type insertResult int64
+
+func (r insertResult) LastInsertId() (int64, error) { return int64(r), nil }
+func (r insertResult) RowsAffected() (int64, error) { return 1, nil }
+
+func isPostgres(driverName string) bool {
+	switch driverName {
+	case "postgres", "pgx", "pq-timeouts", "cloudsqlpostgres":
+		return true
+	}
+	return false
+}
+
+// UpdateByPK updates every column of the row of table identified by the
+// primary key of row, a db-tagged struct. It runs as NamedExec.
+func (db *DB) UpdateByPK(ctx context.Context, table string, row interface{}) (sql.Result, error) {
+	cols, err := db.columns(reflect.TypeOf(row))
+	if err != nil {
+		return nil, err
+	}
+	pks, others := splitPK(cols)
+	if len(pks) == 0 {
+		return nil, ErrNoPK
+	}
+	if len(others) == 0 {
+		return nil, xerrors.Errorf("sqlxx: no columns to update in %T", row)
+	}
+
+	query := "UPDATE " + table + " SET " + namedConds(others, ", ") + " WHERE " + namedConds(pks, " AND ")
+	return db.NamedExec(ctx, query, row)
+}
+
+// DeleteByPK deletes the row of table identified by the primary key of row,
+// a db-tagged struct. It runs as NamedExec.
+func (db *DB) DeleteByPK(ctx context.Context, table string, row interface{}) (sql.Result, error) {
+	cols, err := db.columns(reflect.TypeOf(row))
+	if err != nil {
+		return nil, err
+	}
+	pks, _ := splitPK(cols)
+	if len(pks) == 0 {
+		return nil, ErrNoPK
+	}
+
+	query := "DELETE FROM " + table + " WHERE " + namedConds(pks, " AND ")
+	return db.NamedExec(ctx, query, row)
+}
+
+// FindByPK gets the row of table whose primary key is pk into dest, a
+// pointer to a db-tagged struct. pk holds the values of the primary key
+// columns in the order of the fields. It runs as Get, so sql.ErrNoRows is
+// returned if the row does not exist.
+func (db *DB) FindByPK(ctx context.Context, dest interface{}, table string, pk ...interface{}) error {
+	cols, err := db.columns(reflect.TypeOf(dest))
+	if err != nil {
+		return err
+	}
+	pks, _ := splitPK(cols)
+	if len(pks) == 0 {
+		return ErrNoPK
+	}
+	if len(pk) != len(pks) {
+		return xerrors.Errorf("sqlxx: want %d primary key values, got %d", len(pks), len(pk))
+	}
+
+	conds := make([]string, len(pks))
+	for i, c := range pks {
+		conds[i] = c.name + " = ?"
+	}
+	query := "SELECT " + strings.Join(columnNames(cols), ", ") + " FROM " + table + " WHERE " + strings.Join(conds, " AND ")
+	return db.Get(ctx, dest, db.dbx.Rebind(query), pk...)
+}
+
+func (c column) isPK() bool {
+	_, ok := c.opts["pk"]
+	return ok
+}
+
+func splitPK(cols []column) (pks, others []column) {
+	for _, c := range cols {
+		if c.isPK() {
+			pks = append(pks, c)
+		} else {
+			others = append(others, c)
+		}
+	}
+	return pks, others
+}
+
+// autoIncrementPK returns the primary key column of struct v if it is the
+// only one, of an integer type and zero.
+func autoIncrementPK(v reflect.Value, cols []column) *column {
+	pks, _ := splitPK(cols)
+	if len(pks) != 1 {
+		return nil
+	}
+	f := reflectx.FieldByIndexesReadOnly(v, pks[0].index)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if f.IsZero() {
+			return &pks[0]
+		}
+	}
+	return nil
+}
+
+func namedConds(cols []column, sep string) string {
+	conds := make([]string, len(cols))
+	for i, c := range cols {
+		conds[i] = c.name + " = :" + c.name
+	}
+	return strings.Join(conds, sep)
+}
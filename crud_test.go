@@ -0,0 +1,151 @@
+package sqlxx
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type crudUser struct {
+	ID       int64  `db:"id,pk"`
+	Email    string `db:"email"`
+	Password string `db:"password"`
+}
+
+type crudSession struct {
+	ID       string `db:"id,pk"`
+	CSRF     string `db:"csrf"`
+	UserID   int64  `db:"user_id"`
+	ExpireAt int64  `db:"expire_at"`
+}
+
+func TestCRUD(t *testing.T) {
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil)
+	errRollback := errors.New("rollback")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		u := &crudUser{Email: "crud@example.com", Password: testPassword}
+		if _, err := db.Insert(ctx, "user", u); err != nil {
+			t.Fatal(err)
+		}
+		if u.ID == 0 {
+			t.Fatal("want the auto-increment id populated")
+		}
+
+		var got crudUser
+		if err := db.FindByPK(ctx, &got, "user", u.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got != *u {
+			t.Errorf("want %v, got %v", *u, got)
+		}
+
+		s := crudSession{ID: "crud-session", CSRF: "csrf", UserID: u.ID, ExpireAt: 100}
+		if _, err := db.Insert(ctx, "session", s); err != nil {
+			t.Fatal(err)
+		}
+		var gotS crudSession
+		if err := db.FindByPK(ctx, &gotS, "session", s.ID); err != nil {
+			t.Fatal(err)
+		}
+		if gotS != s {
+			t.Errorf("want %v, got %v", s, gotS)
+		}
+
+		u.Password = "updated"
+		res, err := db.UpdateByPK(ctx, "user", u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			t.Errorf("want 1 row updated, got %d", n)
+		}
+		if err := db.FindByPK(ctx, &got, "user", u.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got.Password != "updated" {
+			t.Errorf("want updated, got %v", got.Password)
+		}
+
+		res, err = db.DeleteByPK(ctx, "session", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			t.Errorf("want 1 row deleted, got %d", n)
+		}
+		if err := db.FindByPK(ctx, &gotS, "session", s.ID); err != sql.ErrNoRows {
+			t.Errorf("want sql.ErrNoRows, got %v", err)
+		}
+
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"[N-EXEC]", "INSERT INTO user (email, password) VALUES (:email, :password) [crud@example.com, " + testPassword + "]",
+		"INSERT INTO session (id, csrf, user_id, expire_at) VALUES (:id, :csrf, :user_id, :expire_at)",
+		"UPDATE user SET email = :email, password = :password WHERE id = :id [crud@example.com, updated, ",
+		"DELETE FROM session WHERE id = :id [crud-session]",
+		"[GET]", "SELECT id, email, password FROM user WHERE id = ? [",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %q in the log, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestCRUDError(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := db.UpdateByPK(ctx, "user", User{}); err != ErrNoPK {
+		t.Errorf("UpdateByPK: want ErrNoPK, got %v", err)
+	}
+	if _, err := db.DeleteByPK(ctx, "user", User{}); err != ErrNoPK {
+		t.Errorf("DeleteByPK: want ErrNoPK, got %v", err)
+	}
+	var u User
+	if err := db.FindByPK(ctx, &u, "user", 1); err != ErrNoPK {
+		t.Errorf("FindByPK: want ErrNoPK, got %v", err)
+	}
+	var cu crudUser
+	if err := db.FindByPK(ctx, &cu, "user", 1, 2); err == nil {
+		t.Error("FindByPK: want non-nil error for wrong number of keys")
+	}
+	if _, err := db.UpdateByPK(ctx, "user", struct {
+		ID int64 `db:"id,pk"`
+	}{1}); err == nil {
+		t.Error("UpdateByPK: want non-nil error for no columns")
+	}
+	if _, err := db.Insert(ctx, "user", 1); err == nil {
+		t.Error("Insert: want non-nil error for non-struct")
+	}
+}
+
+func TestInsertQuery(t *testing.T) {
+	auto := &column{name: "id"}
+	names := []string{"email", "password"}
+
+	tests := []struct {
+		driver string
+		auto   *column
+		want   string
+	}{
+		{"mysql", auto, "INSERT INTO user (email, password) VALUES (:email, :password)"},
+		{"postgres", auto, "INSERT INTO user (email, password) VALUES (:email, :password) RETURNING id"},
+		{"pgx", auto, "INSERT INTO user (email, password) VALUES (:email, :password) RETURNING id"},
+		{"pgx", nil, "INSERT INTO user (email, password) VALUES (:email, :password)"},
+	}
+
+	for i, tt := range tests {
+		if got := insertQuery(tt.driver, "user", names, tt.auto); got != tt.want {
+			t.Errorf("#%d: want %q, got %q", i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/xerrors"
+)
+
+// QueryError is the error of a query returned by a DB made by WrapErrors.
+// It wraps the error of the driver, e.g. sql.ErrNoRows or
+// *mysql.MySQLError, for errors.Is and errors.As.
+type QueryError struct {
+	Command  string
+	Query    string
+	Args     []interface{} // nil if the params are hidden
+	Duration time.Duration
+	InTx     bool
+	Err      error
+}
+
+func (e *QueryError) Error() string {
+	var b strings.Builder
+	b.WriteString("sqlxx: [" + e.Command + "] " + e.Query)
+	if e.Args != nil {
+		b.WriteString(" ")
+		writeArgs(&b, e.Args)
+	}
+	b.WriteString(": " + e.Err.Error())
+	return b.String()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+func (db *DB) queryError(info *QueryInfo, err error, d time.Duration) error {
+	qe := &QueryError{
+		Command:  info.Command,
+		Query:    info.Query,
+		Duration: d,
+		InTx:     info.InTx,
+		Err:      err,
+	}
+	if !db.hideParams {
+		qe.Args = info.Args
+		if qe.Args == nil {
+			qe.Args = []interface{}{}
+		}
+	}
+	return qe
+}
+
+// ErrorKind is the class of a database error, independent of the driver.
+type ErrorKind int
+
+const (
+	ErrorUnknown ErrorKind = iota
+	ErrorUniqueViolation
+	ErrorForeignKeyViolation
+	ErrorDeadlock
+	ErrorLockTimeout
+	ErrorSerialization
+	ErrorConnection
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorUniqueViolation:
+		return "unique_violation"
+	case ErrorForeignKeyViolation:
+		return "foreign_key_violation"
+	case ErrorDeadlock:
+		return "deadlock"
+	case ErrorLockTimeout:
+		return "lock_timeout"
+	case ErrorSerialization:
+		return "serialization_failure"
+	case ErrorConnection:
+		return "connection"
+	}
+	return "unknown"
+}
+
+// KindOf returns the kind of the first driver error in the chain of err,
+// and the name of the violated constraint if the driver exposes it.
+// Supported are go-sql-driver/mysql, lib/pq, pgx, mattn/go-sqlite3 and
+// modernc.org/sqlite, recognized by their exported fields and methods, so
+// that the drivers are not dependencies of sqlxx.
+//
+//	                MySQL             PostgreSQL  SQLite
+//	unique          1062, 1586        23505       1555, 2067
+//	foreign key     1216, 1217, 1451  23503       787
+//	                1452
+//	deadlock        1213              40P01
+//	lock timeout    1205, 3572        55P03       5, 6
+//	serialization                     40001
+//	connection      ErrInvalidConn    08xxx
+//
+// driver.ErrBadConn, sql.ErrConnDone and net.Error are connection errors
+// of any driver. Errors of context.Canceled and context.DeadlineExceeded are
+// ErrorUnknown.
+func KindOf(err error) (kind ErrorKind, constraint string) {
+	// context.DeadlineExceeded is a net.Error, but it is the deadline of the
+	// caller and says nothing about the connection.
+	if xerrors.Is(err, context.Canceled) || xerrors.Is(err, context.DeadlineExceeded) {
+		return ErrorUnknown, ""
+	}
+
+	for e := err; e != nil; e = xerrors.Unwrap(e) {
+		if kind, constraint, ok := classify(e); ok {
+			return kind, constraint
+		}
+	}
+
+	var netErr net.Error
+	if xerrors.Is(err, driver.ErrBadConn) || xerrors.Is(err, sql.ErrConnDone) || xerrors.As(err, &netErr) {
+		return ErrorConnection, ""
+	}
+	return ErrorUnknown, ""
+}
+
+// IsUniqueViolation reports whether err is a unique or primary key
+// violation. See KindOf.
+func IsUniqueViolation(err error) bool {
+	kind, _ := KindOf(err)
+	return kind == ErrorUniqueViolation
+}
+
+// IsForeignKeyViolation reports whether err is a foreign key violation. See KindOf.
+func IsForeignKeyViolation(err error) bool {
+	kind, _ := KindOf(err)
+	return kind == ErrorForeignKeyViolation
+}
+
+// IsDeadlock reports whether err is a deadlock. See KindOf.
+func IsDeadlock(err error) bool {
+	kind, _ := KindOf(err)
+	return kind == ErrorDeadlock
+}
+
+// IsLockTimeout reports whether err is a timeout waiting for a lock. See KindOf.
+func IsLockTimeout(err error) bool {
+	kind, _ := KindOf(err)
+	return kind == ErrorLockTimeout
+}
+
+// IsConnectionError reports whether err is a broken or refused connection. See KindOf.
+func IsConnectionError(err error) bool {
+	kind, _ := KindOf(err)
+	return kind == ErrorConnection
+}
+
+// classify returns the kind of e if e itself is a driver error.
+func classify(e error) (ErrorKind, string, bool) {
+	if e == mysql.ErrInvalidConn {
+		return ErrorConnection, "", true
+	}
+	if myErr, ok := e.(*mysql.MySQLError); ok {
+		return mysqlKind(myErr), mysqlConstraint(myErr), true
+	}
+
+	// pgx: *pgconn.PgError has SQLState and ConstraintName.
+	if stateErr, ok := e.(interface{ SQLState() string }); ok {
+		return pgKind(stateErr.SQLState()), stringField(e, "ConstraintName", "Constraint"), true
+	}
+	// modernc.org/sqlite: *sqlite.Error has Code returning the extended code.
+	if codeErr, ok := e.(interface{ Code() int }); ok {
+		return sqliteKind(codeErr.Code()), sqliteConstraint(e.Error()), true
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(e))
+	if v.Kind() != reflect.Struct {
+		return ErrorUnknown, "", false
+	}
+	// lib/pq: pq.Error has Code of SQLSTATE and Constraint.
+	if code := v.FieldByName("Code"); code.IsValid() && code.Kind() == reflect.String && code.Len() == 5 {
+		return pgKind(code.String()), stringField(e, "Constraint", "ConstraintName"), true
+	}
+	// mattn/go-sqlite3: sqlite3.Error has ExtendedCode.
+	if code := v.FieldByName("ExtendedCode"); code.IsValid() && code.Kind() == reflect.Int {
+		return sqliteKind(int(code.Int())), sqliteConstraint(e.Error()), true
+	}
+	return ErrorUnknown, "", false
+}
+
+func mysqlKind(e *mysql.MySQLError) ErrorKind {
+	switch e.Number {
+	case 1062, 1586:
+		return ErrorUniqueViolation
+	case 1216, 1217, 1451, 1452:
+		return ErrorForeignKeyViolation
+	case 1213:
+		return ErrorDeadlock
+	case 1205, 3572:
+		return ErrorLockTimeout
+	}
+	return ErrorUnknown
+}
+
+// mysqlConstraint parses the key name from "Duplicate entry 'a' for key
+// 'email'" or the constraint name from "... a foreign key constraint fails
+// (`db`.`session`, CONSTRAINT `session_ibfk_1` FOREIGN KEY ...)".
+func mysqlConstraint(e *mysql.MySQLError) string {
+	switch mysqlKind(e) {
+	case ErrorUniqueViolation:
+		return between(e.Message, "for key '", "'")
+	case ErrorForeignKeyViolation:
+		return between(e.Message, "CONSTRAINT `", "`")
+	}
+	return ""
+}
+
+func pgKind(code string) ErrorKind {
+	switch {
+	case code == "23505":
+		return ErrorUniqueViolation
+	case code == "23503":
+		return ErrorForeignKeyViolation
+	case code == "40P01":
+		return ErrorDeadlock
+	case code == "55P03":
+		return ErrorLockTimeout
+	case code == "40001":
+		return ErrorSerialization
+	case strings.HasPrefix(code, "08"):
+		return ErrorConnection
+	}
+	return ErrorUnknown
+}
+
+func sqliteKind(code int) ErrorKind {
+	switch code {
+	case 1555, 2067: // SQLITE_CONSTRAINT_PRIMARYKEY, SQLITE_CONSTRAINT_UNIQUE
+		return ErrorUniqueViolation
+	case 787: // SQLITE_CONSTRAINT_FOREIGNKEY
+		return ErrorForeignKeyViolation
+	}
+	switch code & 0xff {
+	case 5, 6: // SQLITE_BUSY, SQLITE_LOCKED
+		return ErrorLockTimeout
+	}
+	return ErrorUnknown
+}
+
+// sqliteConstraint parses the columns from "UNIQUE constraint failed:
+// user.email", as SQLite does not report constraint names.
+func sqliteConstraint(msg string) string {
+	const marker = "UNIQUE constraint failed: "
+	if i := strings.Index(msg, marker); i >= 0 {
+		return msg[i+len(marker):]
+	}
+	return ""
+}
+
+func stringField(e error, names ...string) string {
+	v := reflect.Indirect(reflect.ValueOf(e))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	for _, name := range names {
+		if f := v.FieldByName(name); f.IsValid() && f.Kind() == reflect.String {
+			return f.String()
+		}
+	}
+	return ""
+}
+
+func between(s, start, end string) string {
+	i := strings.Index(s, start)
+	if i < 0 {
+		return ""
+	}
+	s = s[i+len(start):]
+	if j := strings.Index(s, end); j >= 0 {
+		return s[:j]
+	}
+	return ""
+}
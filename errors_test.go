@@ -0,0 +1,183 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/xerrors"
+)
+
+// Errors shaped like those of the drivers which are not dependencies.
+type (
+	pqError struct {
+		Code       string
+		Constraint string
+	}
+	pgError struct {
+		Code           string
+		ConstraintName string
+	}
+	sqlite3Error struct {
+		Code         int
+		ExtendedCode int
+		msg          string
+	}
+	moderncError struct {
+		code int
+		msg  string
+	}
+)
+
+func (e *pqError) Error() string      { return "pq: " + e.Code }
+func (e *pgError) Error() string      { return "pgx: " + e.Code }
+func (e *pgError) SQLState() string   { return e.Code }
+func (e sqlite3Error) Error() string  { return e.msg }
+func (e *moderncError) Error() string { return e.msg }
+func (e *moderncError) Code() int     { return e.code }
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		err            error
+		wantKind       ErrorKind
+		wantConstraint string
+	}{
+		{nil, ErrorUnknown, ""},
+		{errors.New("some error"), ErrorUnknown, ""},
+		{sql.ErrNoRows, ErrorUnknown, ""},
+
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'email'"}, ErrorUniqueViolation, "email"},
+		{&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`db`.`session`, CONSTRAINT `session_ibfk_1` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`))"}, ErrorForeignKeyViolation, "session_ibfk_1"},
+		{&mysql.MySQLError{Number: 1213}, ErrorDeadlock, ""},
+		{&mysql.MySQLError{Number: 1205}, ErrorLockTimeout, ""},
+		{&mysql.MySQLError{Number: 1064}, ErrorUnknown, ""},
+		{mysql.ErrInvalidConn, ErrorConnection, ""},
+
+		{&pqError{Code: "23505", Constraint: "user_email_key"}, ErrorUniqueViolation, "user_email_key"},
+		{&pqError{Code: "23503", Constraint: "session_user_id_fkey"}, ErrorForeignKeyViolation, "session_user_id_fkey"},
+		{&pqError{Code: "08006"}, ErrorConnection, ""},
+		{&pgError{Code: "23505", ConstraintName: "user_email_key"}, ErrorUniqueViolation, "user_email_key"},
+		{&pgError{Code: "40P01"}, ErrorDeadlock, ""},
+		{&pgError{Code: "55P03"}, ErrorLockTimeout, ""},
+		{&pgError{Code: "40001"}, ErrorSerialization, ""},
+
+		{sqlite3Error{Code: 19, ExtendedCode: 2067, msg: "UNIQUE constraint failed: user.email"}, ErrorUniqueViolation, "user.email"},
+		{sqlite3Error{Code: 19, ExtendedCode: 787, msg: "FOREIGN KEY constraint failed"}, ErrorForeignKeyViolation, ""},
+		{sqlite3Error{Code: 5, ExtendedCode: 5, msg: "database is locked"}, ErrorLockTimeout, ""},
+		{&moderncError{code: 1555, msg: "constraint failed: UNIQUE constraint failed: user.id (1555)"}, ErrorUniqueViolation, "user.id (1555)"},
+
+		{driver.ErrBadConn, ErrorConnection, ""},
+		{sql.ErrConnDone, ErrorConnection, ""},
+		{context.Canceled, ErrorUnknown, ""},
+		{context.DeadlineExceeded, ErrorUnknown, ""},
+		{xerrors.Errorf("wrapped: %w", context.DeadlineExceeded), ErrorUnknown, ""},
+		{xerrors.Errorf("wrapped: %w", &pgError{Code: "23505", ConstraintName: "user_email_key"}), ErrorUniqueViolation, "user_email_key"},
+	}
+
+	for i, tt := range tests {
+		kind, constraint := KindOf(tt.err)
+		if kind != tt.wantKind {
+			t.Errorf("#%d: want %v, got %v", i, tt.wantKind, kind)
+		}
+		if constraint != tt.wantConstraint {
+			t.Errorf("#%d: want %q, got %q", i, tt.wantConstraint, constraint)
+		}
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	errRollback := errors.New("rollback")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		const query = "INSERT INTO user (email, password) VALUES (?, ?)"
+		if _, err := db.Exec(ctx, query, "unique@example.com", testPassword); err != nil {
+			t.Fatal(err)
+		}
+		_, err := db.Exec(ctx, query, "unique@example.com", testPassword)
+		if !IsUniqueViolation(err) {
+			t.Errorf("want unique violation, got %v", err)
+		}
+		if IsForeignKeyViolation(err) || IsDeadlock(err) || IsLockTimeout(err) || IsConnectionError(err) {
+			t.Errorf("want only unique violation, got %v", err)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	ctx := context.Background()
+	const query = "SELECT id, email, password FROM user WHERE email = ?"
+
+	var u User
+	if err := db.Get(ctx, &u, query, "none@example.com"); err != sql.ErrNoRows {
+		t.Errorf("want sql.ErrNoRows unwrapped by default, got %v", err)
+	}
+
+	err := db.WrapErrors().Get(ctx, &u, query, "none@example.com")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("want sql.ErrNoRows, got %v", err)
+	}
+	var qe *QueryError
+	if !errors.As(err, &qe) {
+		t.Fatalf("want *QueryError, got %T", err)
+	}
+	if qe.Command != CmdGet || qe.Query != query || len(qe.Args) != 1 || qe.Args[0] != "none@example.com" || qe.InTx || qe.Duration <= 0 {
+		t.Errorf("unexpected QueryError: %+v", qe)
+	}
+	if want := "sqlxx: [GET] " + query + " [none@example.com]: " + sql.ErrNoRows.Error(); err.Error() != want {
+		t.Errorf("want %q, got %q", want, err.Error())
+	}
+
+	err = db.WrapErrors().Secret().Get(ctx, &u, query, "none@example.com")
+	if !errors.As(err, &qe) || qe.Args != nil {
+		t.Errorf("want *QueryError without args, got %v", err)
+	}
+
+	eachCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	const each = "SELECT 1 UNION ALL SELECT 2"
+	err = db.WrapErrors().Each(eachCtx, each, nil, func(rows *Rows) error {
+		cancel()
+		for rows.Err() == nil { // wait for database/sql to abort the iteration
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if !errors.As(err, &qe) || qe.Command != CmdEach || qe.Query != each {
+		t.Errorf("want *QueryError of Each, got %v", err)
+	}
+
+	errRollback := errors.New("rollback")
+	wdb := db.WrapErrors()
+	txErr, _ := wdb.RunInTx(ctx, func(ctx context.Context) error {
+		const insert = "INSERT INTO user (email, password) VALUES (?, ?)"
+		if _, err := wdb.Exec(ctx, insert, "queryerror@example.com", testPassword); err != nil {
+			t.Fatal(err)
+		}
+		_, err := wdb.Exec(ctx, insert, "queryerror@example.com", testPassword)
+		var myErr *mysql.MySQLError
+		if !errors.As(err, &myErr) || myErr.Number != 1062 {
+			t.Errorf("want *mysql.MySQLError 1062, got %v", err)
+		}
+		if !IsUniqueViolation(err) {
+			t.Errorf("want unique violation, got %v", err)
+		}
+		if !errors.As(err, &qe) || !qe.InTx || qe.Command != CmdExec {
+			t.Errorf("want *QueryError in tx, got %+v", qe)
+		}
+		return errRollback
+	})
+	if txErr != errRollback {
+		t.Fatal(txErr)
+	}
+}
@@ -0,0 +1,199 @@
+package sqlxx
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	inListRe = regexp.MustCompile(`\bin ?\( ?\?(?: ?, ?\?)* ?\)`)
+	valuesRe = regexp.MustCompile(`\bvalues ?\( ?\?(?: ?, ?\?)* ?\)(?: ?, ?\( ?\?(?: ?, ?\?)* ?\))*`)
+)
+
+// Fingerprint normalizes query so that queries differing only in literals,
+// comments and whitespace share the same value: literals and placeholders,
+// including named ones, are replaced with ?, comments are removed,
+// whitespace is collapsed and keywords are lower-cased. Lists of ? in IN
+// and the rows of a multi-row VALUES are collapsed into (?+), e.g.
+//
+//	SELECT * FROM user WHERE id = 42 AND email IN ('a','b')
+//	select * from user where id = ? and email in (?+)
+//
+// As in MySQL, '...' and "..." are literals, and identifiers quoted with `
+// are kept verbatim. Use FingerprintFor for PostgreSQL and SQLite.
+func Fingerprint(query string) string {
+	return fingerprint(query, false)
+}
+
+// FingerprintFor is Fingerprint for the driver driverName. For PostgreSQL
+// and SQLite, identifiers quoted with " are kept verbatim instead of being
+// replaced as literals.
+func FingerprintFor(driverName, query string) string {
+	return fingerprint(query, quotesIdent(driverName))
+}
+
+func quotesIdent(driverName string) bool {
+	return isPostgres(driverName) || driverName == "sqlite3" || driverName == "sqlite"
+}
+
+func fingerprint(query string, dquoteIdent bool) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' && !dquoteIdent:
+			i = skipQuoted(query, i)
+			c = '?'
+		case c == '"' || c == '`':
+			end := skipQuotedIdent(query, i)
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteString(query[i : end+1]) // identifier, kept verbatim
+			i = end
+			continue
+		case c == '-' && i+1 < len(query) && query[i+1] == '-',
+			c == '#' && (i+1 == len(query) || query[i+1] != '>' && query[i+1] != '-'):
+			for i+1 < len(query) && query[i+1] != '\n' {
+				i++
+			}
+			space = true
+			continue
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+			space = true
+			continue
+		case c == '0' && i+1 < len(query) && (query[i+1] == 'x' || query[i+1] == 'X') && (i == 0 || !isIdent(query[i-1])):
+			for i++; i+1 < len(query) && isHex(query[i+1]); i++ {
+			}
+			c = '?'
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]) && (i == 0 || !isIdent(query[i-1])):
+			for i+1 < len(query) && isDigit(query[i+1]) {
+				i++
+			}
+			c = '?'
+		case (isDigit(c) || c == '.' && i+1 < len(query) && isDigit(query[i+1])) && (i == 0 || !isIdent(query[i-1])):
+			i = skipNumber(query, i)
+			c = '?'
+		case c == ':' && i+1 < len(query) && isNameStart(query[i+1]) && (i == 0 || !isIdent(query[i-1]) && query[i-1] != ':'):
+			for i+1 < len(query) && isIdent(query[i+1]) {
+				i++
+			}
+			c = '?'
+		case unicode.IsSpace(rune(c)):
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteByte(lower(c))
+	}
+
+	fp := b.String()
+	if strings.Contains(fp, "in") {
+		fp = inListRe.ReplaceAllString(fp, "in (?+)")
+	}
+	if strings.Contains(fp, "values") {
+		fp = valuesRe.ReplaceAllString(fp, "values (?+)")
+	}
+	return fp
+}
+
+// queryFingerprint normalizes query for the driver of db.
+func (db *DB) queryFingerprint(query string) string {
+	if db.dbx == nil {
+		return Fingerprint(query)
+	}
+	return FingerprintFor(db.dbx.DriverName(), query)
+}
+
+// FingerprintHash returns a short hash of fingerprint, a result of
+// Fingerprint, as 16 hex digits.
+func FingerprintHash(fingerprint string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(fingerprint))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// skipQuoted returns the index of the quote closing the literal starting at i.
+func skipQuoted(s string, i int) int {
+	q := s[i]
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case q:
+			if i+1 < len(s) && s[i+1] == q {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(s) - 1
+}
+
+// skipQuotedIdent returns the index of the quote closing the identifier
+// starting at i. A doubled quote escapes itself; backslashes do not.
+func skipQuotedIdent(s string, i int) int {
+	q := s[i]
+	for i++; i < len(s); i++ {
+		if s[i] == q {
+			if i+1 < len(s) && s[i+1] == q {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(s) - 1
+}
+
+// skipNumber returns the index of the last byte of the number starting at
+// i, including its fraction and exponent, e.g. 1.5e-10.
+func skipNumber(s string, i int) int {
+	for i+1 < len(s) && (isDigit(s[i+1]) || s[i+1] == '.') {
+		i++
+	}
+	if i+1 < len(s) && (s[i+1] == 'e' || s[i+1] == 'E') {
+		j := i + 2
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for i = j; i+1 < len(s) && isDigit(s[i+1]); i++ {
+			}
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isNameStart(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' }
+
+func isHex(c byte) bool { return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' }
+
+func isIdent(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || isDigit(c) || c == '_' || c == '$' || c == '.'
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
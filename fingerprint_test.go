@@ -0,0 +1,77 @@
+package sqlxx
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM user WHERE id = ?;", "select * from user where id = ?;"},
+		{"SELECT * FROM user WHERE id = 42;", "select * from user where id = ?;"},
+		{"SELECT *\n\tFROM   user\n\tWHERE id = 4.2", "select * from user where id = ?"},
+		{"  SELECT 1  ", "select ?"},
+		{"SELECT * FROM user WHERE email = 'a''b' AND password = \"c\\\"d\"", "select * from user where email = ? and password = ?"},
+		{"SELECT * FROM user WHERE email = 'a''b' AND password = 'c\\'d'", "select * from user where email = ? and password = ?"},
+		{`SELECT * FROM user WHERE email = "alice@example.com"`, "select * from user where email = ?"},
+		{"SELECT `Id` FROM `user` WHERE `name`='x'", "select `Id` from `user` where `name`=?"},
+		{"SELECT * FROM t WHERE a = 1e5 AND b = -1.5e10 AND c = .5 AND d = 2E+3", "select * from t where a = ? and b = -? and c = ? and d = ?"},
+		{"SELECT 1e FROM t", "select ?e from t"},
+		{"SELECT id FROM user2 WHERE t1.col3 = 3", "select id from user2 where t1.col3 = ?"},
+		{"SELECT 'unterminated", "select ?"},
+		{"", ""},
+		{"SELECT * FROM user WHERE id = 42 AND email IN ('a','b')", "select * from user where id = ? and email in (?+)"},
+		{"SELECT * FROM user WHERE id NOT IN(?, ?, ?)", "select * from user where id not in (?+)"},
+		{"SELECT * FROM user WHERE id IN (SELECT user_id FROM session)", "select * from user where id in (select user_id from session)"},
+		{"INSERT INTO user (email, password) VALUES ('a', 'b'), ('c', 'd'),\n ('e', 'f')", "insert into user (email, password) values (?+)"},
+		{"INSERT INTO user (email, password) VALUES (?, ?)", "insert into user (email, password) values (?+)"},
+		{"INSERT INTO user (email, created_at) VALUES (?, NOW())", "insert into user (email, created_at) values (?, now())"},
+		{"SELECT /* comment */ id -- comment\nFROM user # comment\nWHERE id = 1", "select id from user where id = ?"},
+		{"SELECT id FROM user /* unterminated", "select id from user"},
+		{"INSERT INTO session (id, user_id) VALUES (:id, :user.id)", "insert into session (id, user_id) values (?+)"},
+		{"SELECT id::text FROM user WHERE email = :email", "select id::text from user where email = ?"},
+		{"SELECT data #> '{a}' FROM t WHERE id = $1 AND x = 0x1F", "select data #> ? from t where id = ? and x = ?"},
+	}
+
+	for _, tt := range tests {
+		if got := Fingerprint(tt.query); got != tt.want {
+			t.Errorf("%q: want %q, got %q", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestFingerprintFor(t *testing.T) {
+	tests := []struct {
+		driverName string
+		query      string
+		want       string
+	}{
+		{"postgres", `SELECT "id" FROM "user" WHERE "name" = 'x'`, `select "id" from "user" where "name" = ?`},
+		{"pgx", `SELECT "Id", "a""b" FROM "User2" WHERE "x 1" = 1`, `select "Id", "a""b" from "User2" where "x 1" = ?`},
+		{"sqlite3", `SELECT "a\" FROM t WHERE id = $1`, `select "a\" from t where id = ?`},
+		{"mysql", `SELECT "id" FROM user WHERE email = "a@example.com"`, `select ? from user where email = ?`},
+		{"", "SELECT `id` FROM user", "select `id` from user"},
+	}
+
+	for _, tt := range tests {
+		if got := FingerprintFor(tt.driverName, tt.query); got != tt.want {
+			t.Errorf("%s: %q: want %q, got %q", tt.driverName, tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestFingerprintHash(t *testing.T) {
+	a := FingerprintHash(Fingerprint("SELECT * FROM user WHERE id IN (1, 2)"))
+	b := FingerprintHash(Fingerprint("select *\nfrom user where id in (3)"))
+	c := FingerprintHash(Fingerprint("SELECT * FROM session WHERE id IN (1, 2)"))
+
+	if len(a) != 16 {
+		t.Errorf("want 16 hex digits, got %q", a)
+	}
+	if a != b {
+		t.Errorf("want the same hash, got %q and %q", a, b)
+	}
+	if a == c {
+		t.Errorf("want different hashes, got %q", a)
+	}
+}
@@ -0,0 +1,56 @@
+package sqlxx
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// GetAs is like DB.Get, but returns the scanned value instead of taking a
+// destination. The zero value of T is returned with any error.
+func GetAs[T any](ctx context.Context, db *DB, query string, args ...interface{}) (T, error) {
+	var dest T
+	if err := db.Get(ctx, &dest, query, args...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return dest, nil
+}
+
+// SelectAs is like DB.Select, but returns the scanned slice instead of
+// taking a destination. A nil slice is returned with any error.
+func SelectAs[T any](ctx context.Context, db *DB, query string, args ...interface{}) ([]T, error) {
+	var dest []T
+	if err := db.Select(ctx, &dest, query, args...); err != nil {
+		return nil, err
+	}
+	return dest, nil
+}
+
+// EachAs is like DB.Each, but calls fn with each row scanned into T, with
+// StructScan if T is a struct or a pointer to a struct, or else Scan.
+func EachAs[T any](ctx context.Context, db *DB, query string, args []interface{}, fn func(T) error) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	t := reflectx.Deref(typ)
+	structScan := t.Kind() == reflect.Struct && !isValueType(t)
+
+	return db.Each(ctx, query, args, func(rows *Rows) error {
+		var row T
+		var err error
+		switch {
+		case structScan && typ.Kind() == reflect.Ptr:
+			v := reflect.New(t)
+			err = rows.StructScan(v.Interface())
+			reflect.ValueOf(&row).Elem().Set(v)
+		case structScan:
+			err = rows.StructScan(&row)
+		default:
+			err = rows.Scan(&row)
+		}
+		if err != nil {
+			return err
+		}
+		return fn(row)
+	})
+}
@@ -0,0 +1,152 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestGetAs(t *testing.T) {
+	errRollback := errors.New("rollback")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		for _, email := range []string{"getas1@example.com", "getas2@example.com"} {
+			if _, err := db.Exec(ctx, "INSERT INTO user (email, password) VALUES (?, ?);", email, testPassword); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		u, err := GetAs[User](ctx, db, "SELECT id, email, password FROM user WHERE email = ?;", "getas1@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := newUser("getas1@example.com", testPassword); !cmp.Equal(u, want, cmpopts.IgnoreFields(User{}, "ID")) {
+			t.Errorf("want %v, got %v", want, u)
+		}
+
+		n, err := GetAs[int](ctx, db, "SELECT COUNT(*) FROM user WHERE email LIKE ?;", "getas%")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("want 2, got %d", n)
+		}
+
+		u, err = GetAs[User](ctx, db, "SELECT id, email, password FROM user WHERE email = ?;", "getas3@example.com")
+		if err != sql.ErrNoRows {
+			t.Errorf("want sql.ErrNoRows, got %v", err)
+		}
+		if u != (User{}) {
+			t.Errorf("want zero value, got %v", u)
+		}
+
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectAs(t *testing.T) {
+	errRollback := errors.New("rollback")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		for _, email := range []string{"selectas1@example.com", "selectas2@example.com"} {
+			if _, err := db.Exec(ctx, "INSERT INTO user (email, password) VALUES (?, ?);", email, testPassword); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		us, err := SelectAs[User](ctx, db, "SELECT id, email, password FROM user WHERE email LIKE ? ORDER BY email;", "selectas%")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []User{newUser("selectas1@example.com", testPassword), newUser("selectas2@example.com", testPassword)}
+		if diff := cmp.Diff(want, us, cmpopts.IgnoreFields(User{}, "ID")); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+
+		emails, err := SelectAs[string](ctx, db, "SELECT email FROM user WHERE email LIKE ? ORDER BY email;", "selectas%")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"selectas1@example.com", "selectas2@example.com"}, emails); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+
+		us, err = SelectAs[User](ctx, db, "SELECT no_such_column FROM user;")
+		if err == nil || us != nil {
+			t.Errorf("want (nil, error), got (%v, %v)", us, err)
+		}
+
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+
+	us, err := SelectAs[User](context.Background(), db, "SELECT id, email, password FROM user WHERE email LIKE ?;", "selectas%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us) != 0 {
+		t.Errorf("want rolled back, got %v", us)
+	}
+}
+
+func TestEachAs(t *testing.T) {
+	errRollback := errors.New("rollback")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		for _, email := range []string{"eachas1@example.com", "eachas2@example.com"} {
+			if _, err := db.Exec(ctx, "INSERT INTO user (email, password) VALUES (?, ?);", email, testPassword); err != nil {
+				t.Fatal(err)
+			}
+		}
+		const query = "SELECT id, email, password FROM user WHERE email LIKE ? ORDER BY email;"
+		args := []interface{}{"eachas%"}
+		want := []User{newUser("eachas1@example.com", testPassword), newUser("eachas2@example.com", testPassword)}
+
+		var us []User
+		if err := EachAs(ctx, db, query, args, func(u User) error {
+			us = append(us, u)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, us, cmpopts.IgnoreFields(User{}, "ID")); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+
+		var ps []User
+		if err := EachAs(ctx, db, query, args, func(u *User) error {
+			ps = append(ps, *u)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, ps, cmpopts.IgnoreFields(User{}, "ID")); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+
+		var emails []string
+		if err := EachAs(ctx, db, "SELECT email FROM user WHERE email LIKE ? ORDER BY email;", args, func(email string) error {
+			emails = append(emails, email)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"eachas1@example.com", "eachas2@example.com"}, emails); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+}
@@ -1,11 +1,35 @@
 module github.com/rema424/sqlxx
 
-go 1.13
+go 1.18
 
 require (
 	github.com/go-sql-driver/mysql v1.5.0
-	github.com/google/go-cmp v0.3.1
+	github.com/google/go-cmp v0.5.6
 	github.com/jmoiron/sqlx v1.2.0
-	golang.org/x/crypto v0.0.0-20200115085410-6d4e4cb37c7d // indirect
+	github.com/prometheus/client_golang v1.11.0
+	github.com/rs/zerolog v1.15.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	go.uber.org/zap v1.14.0
 	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543
 )
+
+require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/golang/protobuf v1.4.3 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.26.0 // indirect
+	github.com/prometheus/procfs v0.6.0 // indirect
+	go.uber.org/atomic v1.5.0 // indirect
+	go.uber.org/multierr v1.3.0 // indirect
+	go.uber.org/tools v0.0.0-20190618225709-2cfd321de3ee // indirect
+	golang.org/x/lint v0.0.0-20190930215403-16217165b5de // indirect
+	golang.org/x/sys v0.0.0-20210603081109-ebe580a85c40 // indirect
+	golang.org/x/tools v0.0.0-20191029190741-b9c20aec41a5 // indirect
+	google.golang.org/protobuf v1.26.0-rc.1 // indirect
+	honnef.co/go/tools v0.0.1-2019.2.3 // indirect
+)
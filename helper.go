@@ -6,8 +6,6 @@ import (
 	"io"
 	"reflect"
 	"time"
-
-	"github.com/jmoiron/sqlx"
 )
 
 func countRows(obj interface{}) int {
@@ -26,12 +24,11 @@ func countRows(obj interface{}) int {
 	case sql.Result:
 		n, _ := obj.RowsAffected()
 		return int(n)
-	case *sqlx.Rows:
-		var n int
-		for obj.Next() {
-			n++
+	case *Rows:
+		if obj == nil {
+			return 0
 		}
-		return n
+		return obj.n
 	default:
 		rv := reflect.ValueOf(obj)
 		if rv.Kind() == reflect.Ptr {
@@ -104,6 +104,10 @@ func TestCountRows(t *testing.T) {
 
 		{&sqlResultMock{0, 45}, 45},
 		{&sqlResultMock{0, 50}, 50},
+
+		{(*Rows)(nil), 0},
+		{&Rows{}, 0},
+		{&Rows{n: 3}, 3},
 	}
 
 	for i, tt := range tests {
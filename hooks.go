@@ -0,0 +1,90 @@
+package sqlxx
+
+import (
+	"context"
+	"sync"
+)
+
+type txHooks struct {
+	mu       sync.Mutex
+	commit   []func(context.Context)
+	rollback []func(context.Context)
+}
+
+type txHooksMark struct{ commit, rollback int }
+
+// OnCommit registers fn to be called after the innermost transaction in ctx
+// is committed. In nested RunInTx calls fn is registered on the outermost
+// transaction, and is discarded if the nested block is rolled back to its
+// savepoint.
+func OnCommit(ctx context.Context, fn func(context.Context)) error {
+	st, _ := ctx.Value(txCtxKey).(*txState)
+	return addHook(st, true, fn)
+}
+
+// OnRollback registers fn to be called after the innermost transaction in
+// ctx is rolled back. If fn is registered in a nested RunInTx block, it is
+// also called when that block is rolled back to its savepoint.
+func OnRollback(ctx context.Context, fn func(context.Context)) error {
+	st, _ := ctx.Value(txCtxKey).(*txState)
+	return addHook(st, false, fn)
+}
+
+// OnCommit is like the package-level OnCommit, but registers fn on the
+// transaction of db.
+func (db *DB) OnCommit(ctx context.Context, fn func(context.Context)) error {
+	return addHook(db.txState(ctx), true, fn)
+}
+
+// OnRollback is like the package-level OnRollback, but registers fn on the
+// transaction of db.
+func (db *DB) OnRollback(ctx context.Context, fn func(context.Context)) error {
+	return addHook(db.txState(ctx), false, fn)
+}
+
+func addHook(st *txState, commit bool, fn func(context.Context)) error {
+	if st == nil || st.tx == nil || st.hooks == nil {
+		return ErrTxRequired
+	}
+	h := st.hooks
+	h.mu.Lock()
+	if commit {
+		h.commit = append(h.commit, fn)
+	} else {
+		h.rollback = append(h.rollback, fn)
+	}
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *txHooks) mark() txHooksMark {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return txHooksMark{len(h.commit), len(h.rollback)}
+}
+
+func (h *txHooks) run(ctx context.Context, committed bool) {
+	h.mu.Lock()
+	fns := h.rollback
+	if committed {
+		fns = h.commit
+	}
+	h.commit, h.rollback = nil, nil
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
+
+func (h *txHooks) rollbackTo(ctx context.Context, m txHooksMark) {
+	h.mu.Lock()
+	fns := h.rollback[m.rollback:]
+	h.commit = h.commit[:m.commit]
+	h.rollback = h.rollback[:m.rollback:m.rollback]
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
@@ -0,0 +1,165 @@
+package sqlxx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestOnCommitNoTx(t *testing.T) {
+	ctx := context.Background()
+	fn := func(context.Context) { t.Error("must not be called") }
+
+	if err := OnCommit(ctx, fn); err != ErrTxRequired {
+		t.Errorf("want ErrTxRequired, got %v", err)
+	}
+	if err := OnRollback(ctx, fn); err != ErrTxRequired {
+		t.Errorf("want ErrTxRequired, got %v", err)
+	}
+
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+		_, _ = db.RunInTxWith(ctx, &TxOption{Propagation: PropagationNotSupported}, func(ctx context.Context) error {
+			if err := OnCommit(ctx, fn); err != ErrTxRequired {
+				t.Errorf("want ErrTxRequired, got %v", err)
+			}
+			return nil
+		})
+		return nil
+	})
+}
+
+func TestTxHooks(t *testing.T) {
+	tests := []struct {
+		name  string
+		txErr error
+		want  []string
+	}{
+		{"commit", nil, []string{"commit-1", "commit-2"}},
+		{"rollback", errors.New("rollback"), []string{"rollback-1", "rollback-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			record := func(s string) func(context.Context) {
+				return func(ctx context.Context) { got = append(got, s) }
+			}
+
+			_, _ = db.RunInTx(context.Background(), func(ctx context.Context) error {
+				_ = OnCommit(ctx, record("commit-1"))
+				_ = OnRollback(ctx, record("rollback-1"))
+				_ = OnCommit(ctx, record("commit-2"))
+				_ = OnRollback(ctx, record("rollback-2"))
+				if len(got) != 0 {
+					t.Errorf("must not be called before the transaction ends: %v", got)
+				}
+				return tt.txErr
+			})
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTxHooksPanic(t *testing.T) {
+	var got []string
+	_, _ = db.RunInTx(context.Background(), func(ctx context.Context) error {
+		_ = OnCommit(ctx, func(context.Context) { got = append(got, "commit") })
+		_ = OnRollback(ctx, func(context.Context) { got = append(got, "rollback") })
+		panic("rollback")
+	})
+
+	if want := []string{"rollback"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestTxHooksNested(t *testing.T) {
+	var got []string
+	record := func(s string) func(context.Context) {
+		return func(ctx context.Context) { got = append(got, s) }
+	}
+
+	_, _ = db.RunInTx(context.Background(), func(ctx context.Context) error {
+		_ = OnCommit(ctx, record("outer-commit"))
+
+		_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			_ = OnCommit(ctx, record("inner-1-commit"))
+			_ = OnRollback(ctx, record("inner-1-rollback"))
+			return nil // release savepoint, registered on the outermost
+		})
+		if len(got) != 0 {
+			t.Errorf("must not be called before the outermost transaction ends: %v", got)
+		}
+
+		_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			_ = OnCommit(ctx, record("inner-2-commit"))
+			_ = OnRollback(ctx, record("inner-2-rollback"))
+			return errors.New("rollback to savepoint")
+		})
+		if want := []string{"inner-2-rollback"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("want %v, got %v", want, got)
+		}
+		got = nil
+
+		return nil
+	})
+
+	if want := []string{"outer-commit", "inner-1-commit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestTxHooksRequiresNew(t *testing.T) {
+	var got []string
+	record := func(s string) func(context.Context) {
+		return func(ctx context.Context) { got = append(got, s) }
+	}
+
+	_, _ = db.RunInTx(context.Background(), func(ctx context.Context) error {
+		_ = OnRollback(ctx, record("outer-rollback"))
+
+		_, _ = db.RunInTxWith(ctx, &TxOption{Propagation: PropagationRequiresNew}, func(ctx context.Context) error {
+			_ = OnCommit(ctx, record("inner-commit"))
+			return nil
+		})
+		if want := []string{"inner-commit"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("want %v, got %v", want, got)
+		}
+
+		return errors.New("rollback")
+	})
+
+	if want := []string{"inner-commit", "outer-rollback"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestTxHooksMultipleDB(t *testing.T) {
+	dbx2, err := sqlx.Connect("mysql", testDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbx2.Close()
+
+	db2 := New(dbx2, nil, nil)
+	fn := func(context.Context) {}
+
+	_, _ = db.RunInTx(context.Background(), func(ctx context.Context) error {
+		if err := db.OnCommit(ctx, fn); err != nil {
+			t.Errorf("db: want nil, got %v", err)
+		}
+		if err := db2.OnCommit(ctx, fn); err != ErrTxRequired {
+			t.Errorf("db2: want ErrTxRequired, got %v", err)
+		}
+		if err := db2.OnRollback(ctx, fn); err != ErrTxRequired {
+			t.Errorf("db2: want ErrTxRequired, got %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,149 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	CmdBegin    = "BEGIN"
+	CmdCommit   = "COMMIT"
+	CmdRollback = "ROLLBACK"
+	// CmdTx and CmdSavepoint wrap a whole transaction block begun by RunInTx,
+	// or joined with a savepoint. The ctx given to next is the one the block
+	// runs in, and the error is the one RunInTx returns.
+	CmdTx        = "TX"
+	CmdSavepoint = "SAVEPOINT"
+)
+
+// QueryInfo describes a query, or the begin, commit or rollback of a
+// transaction, passed through the interceptor chain. An interceptor may
+// change Query and Args before calling next to rewrite the query. Query is
+// empty for transaction commands.
+//
+// For the named commands (CmdNamedExec, CmdNamedGet, CmdNamedSelect and
+// CmdNamedQuery), Args are the values bound to the named parameters; they
+// are informational, and the arg given to the method is executed. With
+// ExpandIn, the named query is bound in advance, and Query and Args are the
+// positional ones executed.
+//
+// For CmdBatchInsert, Query is the statement for a single row and Args is
+// nil; the chunk of rows is executed.
+type QueryInfo struct {
+	Command string
+	Query   string
+	Args    []interface{}
+	InTx    bool
+	// HideParams reports whether Args must not be recorded (see Secret).
+	HideParams bool
+	// WarnDuration and WarnRows are the thresholds of the DB (see Option)
+	// above which a query is logged as slow or as a large result.
+	WarnDuration time.Duration
+	WarnRows     int
+	// TxOptions are the options of the transaction begun by CmdTx, if any.
+	TxOptions *sql.TxOptions
+	// Panicked is set for CmdTx and CmdSavepoint if the block panicked. The
+	// panic is recovered and returned as the error.
+	Panicked bool
+
+	// Rows is the number of rows returned or affected. It is set once the
+	// command finishes (see OnFinish).
+	Rows int
+	// StmtCache is StmtCacheHit or StmtCacheMiss if the command used the
+	// statement cache (see WithStmtCache), or empty otherwise.
+	StmtCache string
+
+	driverName string
+	named      bool
+	arg        interface{}
+	bound      bool
+	streaming  bool
+	finishers  []func(error)
+	finished   bool
+}
+
+// Handler runs the command described by info.
+type Handler func(ctx context.Context, info *QueryInfo) error
+
+// Interceptor wraps the execution of every query and transaction command.
+// It must call next to run the command, unless it decides to fail it.
+type Interceptor func(ctx context.Context, info *QueryInfo, next Handler) error
+
+// OnFinish registers fn to be called with the final error when the command
+// finishes. That is when next returns, except for a successful CmdQuery,
+// which finishes when the returned Rows are exhausted or closed. Functions
+// are called in the order they are registered, so an interceptor that
+// registers fn after next returns sees the inner interceptors finish first.
+func (info *QueryInfo) OnFinish(fn func(err error)) {
+	info.finishers = append(info.finishers, fn)
+}
+
+// Fingerprint returns Query normalized by FingerprintFor the driver of the DB.
+func (info *QueryInfo) Fingerprint() string {
+	return FingerprintFor(info.driverName, info.Query)
+}
+
+func (info *QueryInfo) finish(err error) {
+	if info.finished {
+		return
+	}
+	info.finished = true
+	for _, fn := range info.finishers {
+		fn(err)
+	}
+}
+
+// WithInterceptors returns a copy of db which runs every query and
+// transaction command through ics, in the given order, after the
+// built-in IN expansion and logging interceptors.
+func (db *DB) WithInterceptors(ics ...Interceptor) *DB {
+	var list []Interceptor
+	if db.interceptors != nil {
+		list = append(list, db.interceptors.list...)
+	}
+	clone := db.clone()
+	clone.interceptors = &interceptors{list: append(list, ics...)}
+	return clone
+}
+
+// interceptors is held by pointer so that DB stays comparable.
+type interceptors struct{ list []Interceptor }
+
+func (db *DB) intercept(ctx context.Context, info *QueryInfo, h Handler) error {
+	if info.Command != CmdBegin && info.Command != CmdTx {
+		info.InTx = db.IsInTx(ctx)
+	}
+	info.HideParams = db.hideParams
+	info.WarnDuration = db.warnDuration
+	info.WarnRows = db.warnRows
+	info.driverName = db.dbx.DriverName()
+
+	if db.interceptors != nil {
+		for i := len(db.interceptors.list) - 1; i >= 0; i-- {
+			h = chain(db.interceptors.list[i], h)
+		}
+	}
+	h = chain(db.logInterceptor, h)
+	h = chain(db.inInterceptor, h)
+
+	start := time.Now()
+	err := h(ctx, info)
+	if err != nil || !info.streaming {
+		info.finish(err)
+	}
+	if err != nil && db.wrapErrors && !isTxCommand(info.Command) {
+		err = db.queryError(info, err, time.Since(start))
+	}
+	return err
+}
+
+func chain(ic Interceptor, next Handler) Handler {
+	return func(ctx context.Context, info *QueryInfo) error {
+		return ic(ctx, info, next)
+	}
+}
+
+func isTxCommand(cmd string) bool {
+	return cmd == CmdBegin || cmd == CmdCommit || cmd == CmdRollback || cmd == CmdTx || cmd == CmdSavepoint
+}
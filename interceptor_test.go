@@ -0,0 +1,223 @@
+package sqlxx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterceptorCommands(t *testing.T) {
+	var got []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, info *QueryInfo, next Handler) error {
+			got = append(got, name+":"+info.Command)
+			return next(ctx, info)
+		}
+	}
+	db := New(dbx, nil, nil).WithInterceptors(record("a")).WithInterceptors(record("b"))
+	ctx := context.Background()
+
+	var n int
+	var ids []int
+	_ = db.Get(ctx, &n, "SELECT COUNT(*) FROM user;")
+	_ = db.Select(ctx, &ids, "SELECT id FROM user;")
+	_, _ = db.Exec(ctx, "UPDATE user SET email = email WHERE id = 0;")
+	_, _ = db.NamedExec(ctx, "UPDATE user SET email = email WHERE id = :id;", map[string]interface{}{"id": 0})
+	if rows, err := db.Query(ctx, "SELECT id FROM user;"); err == nil {
+		_ = rows.Close()
+	}
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error { return nil })
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error { return errors.New("rollback") })
+
+	var want []string
+	for _, cmd := range []string{CmdGet, CmdSelect, CmdExec, CmdNamedExec, CmdQuery, CmdTx, CmdBegin, CmdCommit, CmdTx, CmdBegin, CmdRollback} {
+		want = append(want, "a:"+cmd, "b:"+cmd)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestInterceptorInfo(t *testing.T) {
+	var got []QueryInfo
+	db := New(dbx, nil, nil).WithInterceptors(func(ctx context.Context, info *QueryInfo, next Handler) error {
+		err := next(ctx, info)
+		info.OnFinish(func(error) { got = append(got, *info) })
+		return err
+	})
+	ctx := context.Background()
+
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+		var ids []int
+		return db.Select(ctx, &ids, "SELECT id FROM user WHERE id < ?;", 0)
+	})
+
+	want := []struct {
+		cmd   string
+		query string
+		args  int
+		inTx  bool
+	}{
+		{CmdBegin, "", 0, false},
+		{CmdSelect, "SELECT id FROM user WHERE id < ?;", 1, true},
+		{CmdCommit, "", 0, true},
+		{CmdTx, "", 0, false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d infos, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Command != w.cmd || g.Query != w.query || len(g.Args) != w.args || g.InTx != w.inTx {
+			t.Errorf("#%d: want %+v, got %+v", i, w, g)
+		}
+	}
+	if got, want := got[1].Fingerprint(), "select id from user where id < ?;"; got != want {
+		t.Errorf("fingerprint: want %q, got %q", want, got)
+	}
+}
+
+func TestInterceptorTx(t *testing.T) {
+	type key struct{}
+	var got []string
+	db := New(dbx, nil, nil).WithInterceptors(func(ctx context.Context, info *QueryInfo, next Handler) error {
+		got = append(got, fmt.Sprintf("%s:%t:%v", info.Command, info.InTx, ctx.Value(key{})))
+		if info.Command == CmdTx || info.Command == CmdSavepoint {
+			ctx = context.WithValue(ctx, key{}, info.Command)
+		}
+		return next(ctx, info)
+	})
+
+	errRollback := errors.New("rollback")
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		var n int
+		if err := db.Get(ctx, &n, "SELECT COUNT(*) FROM user;"); err != nil {
+			return err
+		}
+		_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			return db.Get(ctx, &n, "SELECT COUNT(*) FROM user;")
+		})
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatalf("want errRollback, got %v", err)
+	}
+
+	want := []string{
+		"TX:false:<nil>",
+		"BEGIN:false:TX",
+		"GET:true:TX",
+		"SAVEPOINT:true:TX",
+		"GET:true:SAVEPOINT",
+		"ROLLBACK:true:TX",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestInterceptorTxPanicked(t *testing.T) {
+	var got []bool
+	db := New(dbx, nil, nil).WithInterceptors(func(ctx context.Context, info *QueryInfo, next Handler) error {
+		err := next(ctx, info)
+		if info.Command == CmdTx || info.Command == CmdSavepoint {
+			got = append(got, info.Panicked)
+		}
+		return err
+	})
+	ctx := context.Background()
+
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+		_, _ = db.RunInTx(ctx, func(ctx context.Context) error { panic("panic") })
+		return nil
+	})
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error { return errors.New("rollback") })
+
+	if want := []bool{true, false, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestInterceptorRewrite(t *testing.T) {
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil).WithInterceptors(func(ctx context.Context, info *QueryInfo, next Handler) error {
+		info.Query = strings.Replace(info.Query, "FROM user", "FROM user WHERE id < ?", 1)
+		info.Args = append(info.Args, 0)
+		return next(ctx, info)
+	})
+
+	var ids []int
+	if err := db.Select(context.Background(), &ids, "SELECT id FROM user;"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("want no rows, got %v", ids)
+	}
+	if got, want := buf.String(), "SELECT id FROM user WHERE id < ?; [0]"; !strings.Contains(got, want) {
+		t.Errorf("want %q in the log, got %q", want, got)
+	}
+}
+
+func TestInterceptorFail(t *testing.T) {
+	errDenied := errors.New("denied")
+	var finished []error
+	db := New(dbx, nil, nil).WithInterceptors(func(ctx context.Context, info *QueryInfo, next Handler) error {
+		info.OnFinish(func(err error) { finished = append(finished, err) })
+		if info.Command == CmdTx || info.Command == CmdBegin {
+			return next(ctx, info)
+		}
+		return errDenied
+	})
+	ctx := context.Background()
+
+	rows, err := db.Query(ctx, "SELECT id FROM user;")
+	if err != errDenied || rows != nil {
+		t.Errorf("want (nil, errDenied), got (%v, %v)", rows, err)
+	}
+
+	called := false
+	err, rbErr := db.RunInTx(ctx, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	if err != errDenied || rbErr != nil {
+		t.Errorf("want (errDenied, nil), got (%v, %v)", err, rbErr)
+	}
+	if !called {
+		t.Error("txFn must be called")
+	}
+
+	if want := []error{errDenied, nil, errDenied, errDenied}; !reflect.DeepEqual(finished, want) {
+		t.Errorf("want %v, got %v", want, finished)
+	}
+}
+
+func TestInterceptorQueryFinish(t *testing.T) {
+	var rows []int
+	db := New(dbx, nil, nil).WithInterceptors(func(ctx context.Context, info *QueryInfo, next Handler) error {
+		err := next(ctx, info)
+		info.OnFinish(func(error) { rows = append(rows, info.Rows) })
+		return err
+	})
+
+	r, err := db.Query(context.Background(), "SELECT id FROM user;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for r.Next() {
+		n++
+	}
+	if len(rows) != 1 {
+		t.Fatalf("want finished on exhaustion, got %v", rows)
+	}
+	_ = r.Close()
+
+	if want := []int{n}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("want %v, got %v", want, rows)
+	}
+}
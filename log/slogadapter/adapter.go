@@ -0,0 +1,61 @@
+//go:build go1.21
+
+// Package slogadapter provides a sqlxx.StructuredLogger for log/slog.
+package slogadapter
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rema424/sqlxx"
+)
+
+type Logger struct {
+	l *slog.Logger
+}
+
+func NewLogger(l *slog.Logger) *Logger {
+	return &Logger{l}
+}
+
+func (l *Logger) Log(ctx context.Context, ev sqlxx.LogEvent) {
+	attrs := make([]slog.Attr, 0, 8)
+	attrs = append(attrs,
+		slog.String("cmd", ev.Command),
+		slog.String("query", ev.Query),
+		slog.Int("rows", ev.Rows),
+		slog.Duration("duration", ev.Duration),
+		slog.Bool("in_tx", ev.InTx),
+	)
+	if ev.Args != nil {
+		attrs = append(attrs, slog.Any("args", ev.Args))
+	}
+	if ev.Attempt > 0 {
+		attrs = append(attrs, slog.Int("attempt", ev.Attempt))
+	}
+	if ev.StmtCache != "" {
+		attrs = append(attrs, slog.String("stmt_cache", ev.StmtCache))
+	}
+	if ev.Fingerprint != "" {
+		attrs = append(attrs, slog.String("fingerprint", ev.Fingerprint))
+	}
+	if ev.Err != nil {
+		attrs = append(attrs, slog.Any("error", ev.Err))
+	}
+
+	l.l.LogAttrs(ctx, level(ev.Level), "sqlxx", attrs...)
+}
+
+func level(lv sqlxx.LogLevel) slog.Level {
+	switch lv {
+	case sqlxx.LevelDebug:
+		return slog.LevelDebug
+	case sqlxx.LevelInfo:
+		return slog.LevelInfo
+	case sqlxx.LevelWarn:
+		return slog.LevelWarn
+	case sqlxx.LevelError:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
@@ -0,0 +1,65 @@
+// Package zapadapter provides a sqlxx.StructuredLogger for go.uber.org/zap.
+package zapadapter
+
+import (
+	"context"
+
+	"github.com/rema424/sqlxx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type Logger struct {
+	l *zap.Logger
+}
+
+func NewLogger(l *zap.Logger) *Logger {
+	return &Logger{l}
+}
+
+func (l *Logger) Log(ctx context.Context, ev sqlxx.LogEvent) {
+	ce := l.l.Check(level(ev.Level), "sqlxx")
+	if ce == nil {
+		return
+	}
+
+	fields := make([]zap.Field, 0, 8)
+	fields = append(fields,
+		zap.String("cmd", ev.Command),
+		zap.String("query", ev.Query),
+		zap.Int("rows", ev.Rows),
+		zap.Duration("duration", ev.Duration),
+		zap.Bool("in_tx", ev.InTx),
+	)
+	if ev.Args != nil {
+		fields = append(fields, zap.Any("args", ev.Args))
+	}
+	if ev.Attempt > 0 {
+		fields = append(fields, zap.Int("attempt", ev.Attempt))
+	}
+	if ev.StmtCache != "" {
+		fields = append(fields, zap.String("stmt_cache", ev.StmtCache))
+	}
+	if ev.Fingerprint != "" {
+		fields = append(fields, zap.String("fingerprint", ev.Fingerprint))
+	}
+	if ev.Err != nil {
+		fields = append(fields, zap.Error(ev.Err))
+	}
+
+	ce.Write(fields...)
+}
+
+func level(lv sqlxx.LogLevel) zapcore.Level {
+	switch lv {
+	case sqlxx.LevelDebug:
+		return zapcore.DebugLevel
+	case sqlxx.LevelInfo:
+		return zapcore.InfoLevel
+	case sqlxx.LevelWarn:
+		return zapcore.WarnLevel
+	case sqlxx.LevelError:
+		return zapcore.ErrorLevel
+	}
+	return zapcore.InfoLevel
+}
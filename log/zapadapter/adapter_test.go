@@ -0,0 +1,100 @@
+package zapadapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rema424/sqlxx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogger(t *testing.T) {
+	someErr := errors.New("some error")
+
+	tests := []struct {
+		ev        sqlxx.LogEvent
+		wantLevel zapcore.Level
+		want      map[string]interface{}
+	}{
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelDebug, Command: sqlxx.CmdGet, Query: "select 1", Args: []interface{}{1}, Rows: 1, Duration: time.Millisecond},
+			zapcore.DebugLevel,
+			map[string]interface{}{"cmd": "GET", "query": "select 1", "args": []interface{}{1}, "rows": int64(1), "duration": time.Millisecond, "in_tx": false},
+		},
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelWarn, Command: sqlxx.CmdExec, Query: "delete", Err: someErr, InTx: true},
+			zapcore.WarnLevel,
+			map[string]interface{}{"cmd": "EXEC", "query": "delete", "rows": int64(0), "duration": time.Duration(0), "in_tx": true, "error": "some error"},
+		},
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelWarn, Command: sqlxx.CmdRetry, Attempt: 2},
+			zapcore.WarnLevel,
+			map[string]interface{}{"cmd": "RETRY", "query": "", "rows": int64(0), "duration": time.Duration(0), "in_tx": false, "attempt": int64(2)},
+		},
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelDebug, Command: sqlxx.CmdGet, Query: "select 1", StmtCache: sqlxx.StmtCacheHit},
+			zapcore.DebugLevel,
+			map[string]interface{}{"cmd": "GET", "query": "select 1", "rows": int64(0), "duration": time.Duration(0), "in_tx": false, "stmt_cache": "hit"},
+		},
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelDebug, Command: sqlxx.CmdGet, Query: "SELECT 1", Fingerprint: "select ?"},
+			zapcore.DebugLevel,
+			map[string]interface{}{"cmd": "GET", "query": "SELECT 1", "rows": int64(0), "duration": time.Duration(0), "in_tx": false, "fingerprint": "select ?"},
+		},
+	}
+
+	for i, tt := range tests {
+		core, logs := observer.New(zapcore.DebugLevel)
+		NewLogger(zap.New(core)).Log(context.Background(), tt.ev)
+
+		entries := logs.AllUntimed()
+		if len(entries) != 1 {
+			t.Fatalf("#%d: want 1 entry, got %d", i, len(entries))
+		}
+		if got := entries[0].Level; got != tt.wantLevel {
+			t.Errorf("#%d: want %v, got %v", i, tt.wantLevel, got)
+		}
+		if got, want := entries[0].Message, "sqlxx"; got != want {
+			t.Errorf("#%d: want %v, got %v", i, want, got)
+		}
+
+		got := entries[0].ContextMap()
+		if len(got) != len(tt.want) {
+			t.Errorf("#%d: want %v, got %v", i, tt.want, got)
+		}
+		for k, want := range tt.want {
+			if g, ok := got[k]; !ok || !equal(g, want) {
+				t.Errorf("#%d: %s: want %v (%T), got %v (%T)", i, k, want, want, g, g)
+			}
+		}
+	}
+}
+
+func TestLoggerLevelDisabled(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	NewLogger(zap.New(core)).Log(context.Background(), sqlxx.LogEvent{Level: sqlxx.LevelDebug})
+
+	if got := logs.Len(); got != 0 {
+		t.Errorf("want no entry, got %d", got)
+	}
+}
+
+func equal(x, y interface{}) bool {
+	if xs, ok := x.([]interface{}); ok {
+		ys, ok := y.([]interface{})
+		if !ok || len(xs) != len(ys) {
+			return false
+		}
+		for i := range xs {
+			if xs[i] != ys[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return x == y
+}
@@ -0,0 +1,62 @@
+// Package zerologadapter provides a sqlxx.StructuredLogger for github.com/rs/zerolog.
+package zerologadapter
+
+import (
+	"context"
+
+	"github.com/rema424/sqlxx"
+	"github.com/rs/zerolog"
+)
+
+type Logger struct {
+	l zerolog.Logger
+}
+
+func NewLogger(l zerolog.Logger) *Logger {
+	return &Logger{l}
+}
+
+func (l *Logger) Log(ctx context.Context, ev sqlxx.LogEvent) {
+	e := l.l.WithLevel(level(ev.Level))
+	if e == nil {
+		return
+	}
+
+	e = e.
+		Str("cmd", ev.Command).
+		Str("query", ev.Query).
+		Int("rows", ev.Rows).
+		Dur("duration", ev.Duration).
+		Bool("in_tx", ev.InTx)
+	if ev.Args != nil {
+		e = e.Interface("args", ev.Args)
+	}
+	if ev.Attempt > 0 {
+		e = e.Int("attempt", ev.Attempt)
+	}
+	if ev.StmtCache != "" {
+		e = e.Str("stmt_cache", ev.StmtCache)
+	}
+	if ev.Fingerprint != "" {
+		e = e.Str("fingerprint", ev.Fingerprint)
+	}
+	if ev.Err != nil {
+		e = e.Err(ev.Err)
+	}
+
+	e.Msg("sqlxx")
+}
+
+func level(lv sqlxx.LogLevel) zerolog.Level {
+	switch lv {
+	case sqlxx.LevelDebug:
+		return zerolog.DebugLevel
+	case sqlxx.LevelInfo:
+		return zerolog.InfoLevel
+	case sqlxx.LevelWarn:
+		return zerolog.WarnLevel
+	case sqlxx.LevelError:
+		return zerolog.ErrorLevel
+	}
+	return zerolog.InfoLevel
+}
@@ -0,0 +1,74 @@
+package zerologadapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rema424/sqlxx"
+	"github.com/rs/zerolog"
+)
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		ev   sqlxx.LogEvent
+		want map[string]interface{}
+	}{
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelDebug, Command: sqlxx.CmdGet, Query: "select 1", Args: []interface{}{1}, Rows: 1, Duration: time.Millisecond},
+			map[string]interface{}{"level": "debug", "message": "sqlxx", "cmd": "GET", "query": "select 1", "args": []interface{}{1.0}, "rows": 1.0, "duration": 1.0, "in_tx": false},
+		},
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelWarn, Command: sqlxx.CmdExec, Query: "delete", Err: errors.New("some error"), InTx: true},
+			map[string]interface{}{"level": "warn", "message": "sqlxx", "cmd": "EXEC", "query": "delete", "rows": 0.0, "duration": 0.0, "in_tx": true, "error": "some error"},
+		},
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelWarn, Command: sqlxx.CmdRetry, Attempt: 2},
+			map[string]interface{}{"level": "warn", "message": "sqlxx", "cmd": "RETRY", "query": "", "rows": 0.0, "duration": 0.0, "in_tx": false, "attempt": 2.0},
+		},
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelDebug, Command: sqlxx.CmdGet, Query: "select 1", StmtCache: sqlxx.StmtCacheHit},
+			map[string]interface{}{"level": "debug", "message": "sqlxx", "cmd": "GET", "query": "select 1", "rows": 0.0, "duration": 0.0, "in_tx": false, "stmt_cache": "hit"},
+		},
+		{
+			sqlxx.LogEvent{Level: sqlxx.LevelDebug, Command: sqlxx.CmdGet, Query: "SELECT 1", Fingerprint: "select ?"},
+			map[string]interface{}{"level": "debug", "message": "sqlxx", "cmd": "GET", "query": "SELECT 1", "rows": 0.0, "duration": 0.0, "in_tx": false, "fingerprint": "select ?"},
+		},
+	}
+
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		NewLogger(zerolog.New(&buf)).Log(context.Background(), tt.ev)
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("#%d: want %v, got %v", i, tt.want, got)
+		}
+		for k, want := range tt.want {
+			if g, ok := got[k]; !ok || !equal(g, want) {
+				t.Errorf("#%d: %s: want %v, got %v", i, k, want, g)
+			}
+		}
+	}
+}
+
+func TestLoggerLevelDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogger(zerolog.New(&buf).Level(zerolog.WarnLevel)).Log(context.Background(), sqlxx.LogEvent{Level: sqlxx.LevelDebug})
+
+	if buf.Len() != 0 {
+		t.Errorf("want no output, got %s", buf.String())
+	}
+}
+
+func equal(x, y interface{}) bool {
+	bx, _ := json.Marshal(x)
+	by, _ := json.Marshal(y)
+	return bytes.Equal(bx, by)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"log"
+	"time"
 )
 
 type loggerFunc func(ctx context.Context, format string, args ...interface{})
@@ -43,3 +44,51 @@ func (li *LoggerImpl) Warnf(ctx context.Context, format string, args ...interfac
 func (li *LoggerImpl) Errorf(ctx context.Context, format string, args ...interface{}) {
 	li.err.Printf(format, args...)
 }
+
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "UNKNOWN"
+}
+
+// LogEvent is passed to StructuredLogger for every query.
+type LogEvent struct {
+	Level   LogLevel
+	Command string
+	Query   string
+	// Args is nil when the parameters are hidden by Secret or Option.HideParams.
+	Args     []interface{}
+	Rows     int
+	Duration time.Duration
+	Err      error
+	InTx     bool
+	// Attempt is set for CmdRetry, whose Duration is the backoff before the next attempt.
+	Attempt int
+	// StmtCache is StmtCacheHit or StmtCacheMiss if the statement cache is used.
+	StmtCache string
+	// Fingerprint is the query normalized by FingerprintFor the driver of the DB.
+	Fingerprint string
+}
+
+// StructuredLogger receives typed log fields instead of a formatted message.
+// Adapters for log/slog, zap and zerolog are in the log directory.
+type StructuredLogger interface {
+	Log(ctx context.Context, ev LogEvent)
+}
@@ -4,8 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestLogger(t *testing.T) {
@@ -73,3 +77,111 @@ func TestLogger(t *testing.T) {
 		}
 	}
 }
+
+type eventRecorder struct{ events []LogEvent }
+
+func (r *eventRecorder) Log(ctx context.Context, ev LogEvent) { r.events = append(r.events, ev) }
+
+func TestStructuredLogger(t *testing.T) {
+	tx, err := dbx.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	ctx := context.Background()
+	someErr := errors.New("some error")
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		hideParams bool
+		args       []interface{}
+		rows       int
+		err        error
+		d          time.Duration
+		want       LogEvent
+	}{
+		{
+			"debug",
+			ctx,
+			false,
+			[]interface{}{1},
+			1,
+			nil,
+			10 * time.Millisecond,
+			LogEvent{LevelDebug, "CMD", "query", []interface{}{1}, 1, 10 * time.Millisecond, nil, false, 0, "", "query"},
+		},
+		{
+			"warn",
+			ctx,
+			false,
+			nil,
+			0,
+			someErr,
+			10 * time.Millisecond,
+			LogEvent{LevelWarn, "CMD", "query", []interface{}{}, 0, 10 * time.Millisecond, someErr, false, 0, "", "query"},
+		},
+		{
+			"hide params",
+			ctx,
+			true,
+			[]interface{}{"secret"},
+			1,
+			nil,
+			10 * time.Millisecond,
+			LogEvent{LevelDebug, "CMD", "query", nil, 1, 10 * time.Millisecond, nil, false, 0, "", "query"},
+		},
+		{
+			"in tx",
+			db.newTxCtx(ctx, tx),
+			false,
+			nil,
+			2000,
+			nil,
+			10 * time.Millisecond,
+			LogEvent{LevelWarn, "CMD", "query", []interface{}{}, 2000, 10 * time.Millisecond, nil, true, 0, "", "query"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			rec := &eventRecorder{}
+			db := New(dbx, NewLogger(&buf), &Option{DefaultWarnDuration, DefaultWarnRows, tt.hideParams}).WithStructuredLogger(rec)
+
+			db.log(tt.ctx, "CMD", "query", tt.args, tt.err, tt.rows, tt.d)
+
+			if len(rec.events) != 1 {
+				t.Fatalf("want 1 event, got %d", len(rec.events))
+			}
+			if diff := cmp.Diff(tt.want, rec.events[0], cmp.Comparer(func(x, y error) bool { return x == y })); diff != "" {
+				t.Errorf("wrong event: \n%s", diff)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("want no printf-style log, got %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestLogLevelString(t *testing.T) {
+	tests := []struct {
+		l    LogLevel
+		want string
+	}{
+		{LevelDebug, "DEBUG"},
+		{LevelInfo, "INFO"},
+		{LevelWarn, "WARN"},
+		{LevelError, "ERROR"},
+		{LogLevel(-1), "UNKNOWN"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.l.String(); got != tt.want {
+			t.Errorf("want %s, got %s", tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package prominterceptor provides a sqlxx.Interceptor which records
+// Prometheus metrics of queries and transactions.
+package prominterceptor
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rema424/sqlxx"
+)
+
+const DefaultNamespace = "sqlxx"
+
+const (
+	txOutcomeCommit   = "commit"
+	txOutcomeRollback = "rollback"
+	txOutcomePanic    = "panic"
+)
+
+// Option configures NewMetrics and NewStatsCollector.
+type Option struct {
+	// Namespace prefixes every metric name. DefaultNamespace is used if empty.
+	Namespace string
+	// Buckets of the query duration histogram in seconds. prometheus.DefBuckets is used if nil.
+	Buckets     []float64
+	ConstLabels prometheus.Labels
+}
+
+// Metrics collects Prometheus metrics of the queries and transactions run by
+// the DBs given Intercept by WithInterceptors. Queries are labelled by
+// command and by QueryInfo.Fingerprint. Slow queries and large results
+// are counted over the WarnDuration and WarnRows of the DB. Metrics
+// implements prometheus.Collector and must be registered to be exported.
+type Metrics struct {
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+	slowQueries   *prometheus.CounterVec
+	largeResults  *prometheus.CounterVec
+	txs           *prometheus.CounterVec
+}
+
+func NewMetrics(opts *Option) *Metrics {
+	if opts == nil {
+		opts = &Option{}
+	}
+	ns := opts.Namespace
+	if ns == "" {
+		ns = DefaultNamespace
+	}
+	buckets := opts.Buckets
+	if buckets == nil {
+		buckets = prometheus.DefBuckets
+	}
+	queryLabels := []string{"command", "query"}
+
+	return &Metrics{
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   ns,
+			Name:        "query_duration_seconds",
+			Help:        "Duration of queries in seconds.",
+			Buckets:     buckets,
+			ConstLabels: opts.ConstLabels,
+		}, queryLabels),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   ns,
+			Name:        "query_errors_total",
+			Help:        "Number of queries which failed, excluding sql.ErrNoRows.",
+			ConstLabels: opts.ConstLabels,
+		}, queryLabels),
+		slowQueries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   ns,
+			Name:        "slow_queries_total",
+			Help:        "Number of queries which took longer than the warn duration.",
+			ConstLabels: opts.ConstLabels,
+		}, queryLabels),
+		largeResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   ns,
+			Name:        "large_results_total",
+			Help:        "Number of queries which returned or affected more rows than the warn rows.",
+			ConstLabels: opts.ConstLabels,
+		}, queryLabels),
+		txs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   ns,
+			Name:        "transactions_total",
+			Help:        "Number of transactions begun by RunInTx by outcome (commit, rollback or panic).",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"outcome"}),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.queryDuration.Describe(ch)
+	m.queryErrors.Describe(ch)
+	m.slowQueries.Describe(ch)
+	m.largeResults.Describe(ch)
+	m.txs.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.queryDuration.Collect(ch)
+	m.queryErrors.Collect(ch)
+	m.slowQueries.Collect(ch)
+	m.largeResults.Collect(ch)
+	m.txs.Collect(ch)
+}
+
+// Intercept is a sqlxx.Interceptor recording the queries and the
+// transactions begun by RunInTx to m.
+func (m *Metrics) Intercept(ctx context.Context, info *sqlxx.QueryInfo, next sqlxx.Handler) error {
+	switch info.Command {
+	case sqlxx.CmdBegin, sqlxx.CmdCommit, sqlxx.CmdRollback, sqlxx.CmdSavepoint:
+		return next(ctx, info)
+	case sqlxx.CmdTx:
+		err := next(ctx, info)
+		m.observeTx(info, err)
+		return err
+	}
+
+	start := time.Now()
+	err := next(ctx, info)
+	info.OnFinish(func(err error) {
+		m.observe(info, err, time.Since(start))
+	})
+	return err
+}
+
+func (m *Metrics) observe(info *sqlxx.QueryInfo, err error, d time.Duration) {
+	labels := prometheus.Labels{"command": info.Command, "query": info.Fingerprint()}
+	m.queryDuration.With(labels).Observe(d.Seconds())
+	if err != nil && err != sql.ErrNoRows {
+		m.queryErrors.With(labels).Inc()
+	}
+	if d > info.WarnDuration {
+		m.slowQueries.With(labels).Inc()
+	}
+	if info.Rows > info.WarnRows {
+		m.largeResults.With(labels).Inc()
+	}
+}
+
+func (m *Metrics) observeTx(info *sqlxx.QueryInfo, err error) {
+	outcome := txOutcomeCommit
+	if info.Panicked {
+		outcome = txOutcomePanic
+	} else if err != nil {
+		outcome = txOutcomeRollback
+	}
+	m.txs.WithLabelValues(outcome).Inc()
+}
+
+// StatsCollector exports the connection pool statistics of a *sqlx.DB
+// (sql.DBStats) as Prometheus metrics.
+type StatsCollector struct {
+	dbx *sqlx.DB
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+func NewStatsCollector(dbx *sqlx.DB, opts *Option) *StatsCollector {
+	if opts == nil {
+		opts = &Option{}
+	}
+	ns := opts.Namespace
+	if ns == "" {
+		ns = DefaultNamespace
+	}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(ns, "db", name), help, nil, opts.ConstLabels)
+	}
+
+	return &StatsCollector{
+		dbx:               dbx,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("open_connections", "Number of established connections both in use and idle."),
+		inUse:             desc("in_use_connections", "Number of connections currently in use."),
+		idle:              desc("idle_connections", "Number of idle connections."),
+		waitCount:         desc("wait_count_total", "Total number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "Total time blocked waiting for a new connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns."),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "Total number of connections closed due to SetConnMaxIdleTime."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime."),
+	}
+}
+
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.dbx.Stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(s.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(s.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(s.MaxLifetimeClosed))
+}
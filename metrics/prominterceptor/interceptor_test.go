@@ -0,0 +1,161 @@
+package prominterceptor
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rema424/sqlxx"
+)
+
+const testDSN = "sqlxxtester:Passw0rd!@tcp(127.0.0.1:3306)/sqlxxtest?collation=utf8mb4_bin&interpolateParams=true&parseTime=true&maxAllowedPacket=0"
+
+var dbx *sqlx.DB
+
+func TestMain(m *testing.M) {
+	var err error
+	dbx, err = sqlx.Connect("mysql", testDSN)
+	if err != nil {
+		log.Fatalf("sqlx.Connect: %v", err)
+	}
+	defer dbx.Close()
+
+	os.Exit(m.Run())
+}
+
+func TestMetricsQuery(t *testing.T) {
+	m := NewMetrics(nil)
+	db := sqlxx.New(dbx, nil, &sqlxx.Option{WarnDuration: time.Nanosecond, WarnRows: 1}).WithInterceptors(m.Intercept)
+	ctx := context.Background()
+
+	var n int
+	if err := db.Get(ctx, &n, "SELECT ?;", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Get(ctx, &n, "SELECT 2;"); err != nil {
+		t.Fatal(err)
+	}
+	var ns []int
+	if err := db.Select(ctx, &ns, "SELECT 1 UNION ALL SELECT 2;"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(ctx, "SELECT no_such_column;"); err == nil {
+		t.Fatal("want non-nil error")
+	}
+
+	get := prometheus.Labels{"command": sqlxx.CmdGet, "query": "select ?;"}
+	sel := prometheus.Labels{"command": sqlxx.CmdSelect, "query": "select ? union all select ?;"}
+	exec := prometheus.Labels{"command": sqlxx.CmdExec, "query": "select no_such_column;"}
+
+	if got, want := testutil.CollectAndCount(m.queryDuration), 3; got != want {
+		t.Errorf("wrong series: got %v, want %v", got, want)
+	}
+	tests := []struct {
+		name string
+		c    prometheus.Collector
+		want float64
+	}{
+		{"get errors", m.queryErrors.With(get), 0},
+		{"get slow", m.slowQueries.With(get), 2},
+		{"get large", m.largeResults.With(get), 0},
+		{"select large", m.largeResults.With(sel), 1},
+		{"exec errors", m.queryErrors.With(exec), 1},
+		{"exec large", m.largeResults.With(exec), 0},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(tt.c); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	problems, err := testutil.CollectAndLint(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("lint: %s: %s", p.Metric, p.Text)
+	}
+}
+
+func TestMetricsRows(t *testing.T) {
+	m := NewMetrics(nil)
+	db := sqlxx.New(dbx, nil, nil).WithInterceptors(m.Intercept)
+
+	rows, err := db.Query(context.Background(), "SELECT 1 UNION ALL SELECT 2;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(m.queryDuration); got != 0 {
+		t.Errorf("must not be observed before the rows are closed: %v", got)
+	}
+	_ = rows.Close()
+
+	if got, want := testutil.CollectAndCount(m.queryDuration), 1; got != want {
+		t.Errorf("wrong series: got %v, want %v", got, want)
+	}
+	if got := testutil.CollectAndCount(m.slowQueries) + testutil.CollectAndCount(m.largeResults); got != 0 {
+		t.Errorf("want no slow queries or large results under the default thresholds, got %v", got)
+	}
+}
+
+func TestMetricsTx(t *testing.T) {
+	m := NewMetrics(nil)
+	db := sqlxx.New(dbx, nil, nil).WithInterceptors(m.Intercept)
+	ctx := context.Background()
+
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+		_, _ = db.RunInTx(ctx, func(ctx context.Context) error { return nil })
+		return nil
+	})
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error { return errors.New("rollback") })
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error { panic("panic") })
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error { return nil })
+
+	tests := []struct {
+		outcome string
+		want    float64
+	}{
+		{txOutcomeCommit, 2},
+		{txOutcomeRollback, 1},
+		{txOutcomePanic, 1},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(m.txs.WithLabelValues(tt.outcome)); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.outcome, got, tt.want)
+		}
+	}
+}
+
+func TestStatsCollector(t *testing.T) {
+	c := NewStatsCollector(dbx, &Option{Namespace: "app", ConstLabels: prometheus.Labels{"db": "test"}})
+
+	if got, want := testutil.CollectAndCount(c), 9; got != want {
+		t.Errorf("wrong metrics: got %v, want %v", got, want)
+	}
+	if got, want := testutil.CollectAndCount(c, "app_db_max_open_connections"), 1; got != want {
+		t.Errorf("wrong metrics: got %v, want %v", got, want)
+	}
+
+	problems, err := testutil.CollectAndLint(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("lint: %s: %s", p.Metric, p.Text)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register(NewMetrics(&Option{Namespace: "app"})); err != nil {
+		t.Fatal(err)
+	}
+}
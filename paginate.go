@@ -0,0 +1,286 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx/reflectx"
+	"golang.org/x/xerrors"
+)
+
+// DefaultPageLimit is the number of rows of a page used when PageOption.Limit is 0.
+const DefaultPageLimit = 20
+
+// ErrInvalidCursor is returned by Paginate when the cursor cannot be decoded
+// or does not match the order columns.
+var ErrInvalidCursor = xerrors.New("sqlxx: invalid cursor")
+
+// PageOrder is a column of the keyset. Column is the name in the result of
+// the base query, mapped to a field of the destination struct. Nullable
+// columns are ordered with NULLs last, in both directions.
+type PageOrder struct {
+	Column   string
+	Desc     bool
+	Nullable bool
+}
+
+// PageOption is an option of Paginate. Order must uniquely identify a row,
+// e.g. end with the primary key. Cursor is Page.Next or Page.Prev of a
+// previous call, or empty for the first page.
+type PageOption struct {
+	Order  []PageOrder
+	Limit  int
+	Cursor string
+}
+
+// Page holds the cursors of the pages after and before the one returned by
+// Paginate. They are empty when there is no such page.
+type Page struct {
+	Next string
+	Prev string
+}
+
+type cursor struct {
+	Values []json.RawMessage `json:"v"`
+	Prev   bool              `json:"p,omitempty"`
+}
+
+// Paginate selects a page of the base query into dest, a pointer to a slice
+// of structs, with keyset pagination: rows after (or before) the cursor in
+// the order of opts.Order, at most opts.Limit rows. The base query is
+// wrapped in a subquery, and its placeholders are written as ?.
+func (db *DB) Paginate(ctx context.Context, dest interface{}, query string, args []interface{}, opts *PageOption) (*Page, error) {
+	if opts == nil || len(opts.Order) == 0 {
+		return nil, xerrors.New("sqlxx: paginate requires order columns")
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return nil, xerrors.Errorf("sqlxx: want pointer to slice, got %T", dest)
+	}
+	cols, err := db.columns(rv.Elem().Type().Elem())
+	if err != nil {
+		return nil, err
+	}
+	cols, err = selectColumns(cols, pageColumns(opts.Order))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cur  cursor
+		vals []interface{}
+	)
+	if opts.Cursor != "" {
+		if cur, vals, err = decodeCursor(opts.Cursor, rv.Elem().Type().Elem(), cols); err != nil {
+			return nil, err
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT * FROM (" + query + ") AS sqlxx_page")
+	args = append([]interface{}{}, args...)
+	if vals != nil {
+		cond, condArgs := keysetCond(opts.Order, vals, cur.Prev)
+		sb.WriteString(" WHERE " + cond)
+		args = append(args, condArgs...)
+	}
+	sb.WriteString(" ORDER BY " + keysetOrder(opts.Order, cur.Prev))
+	sb.WriteString(" LIMIT " + strconv.Itoa(limit+1))
+
+	if err := db.Select(ctx, dest, db.dbx.Rebind(sb.String()), args...); err != nil {
+		return nil, err
+	}
+
+	s := rv.Elem()
+	more := s.Len() > limit
+	if more {
+		s.Set(s.Slice(0, limit))
+	}
+	if cur.Prev {
+		swap := reflect.Swapper(s.Interface())
+		for i, j := 0, s.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	page := &Page{}
+	if s.Len() == 0 {
+		return page, nil
+	}
+	if more || cur.Prev {
+		if page.Next, err = encodeCursor(s.Index(s.Len()-1), cols, false); err != nil {
+			return nil, err
+		}
+	}
+	if (more && cur.Prev) || (!cur.Prev && vals != nil) {
+		if page.Prev, err = encodeCursor(s.Index(0), cols, true); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func pageColumns(order []PageOrder) []string {
+	names := make([]string, len(order))
+	for i, o := range order {
+		names[i] = o.Column
+	}
+	return names
+}
+
+// keysetOrder returns the ORDER BY of order, reversed for the previous page.
+func keysetOrder(order []PageOrder, prev bool) string {
+	terms := make([]string, 0, len(order))
+	for _, o := range order {
+		if o.Nullable {
+			if prev {
+				terms = append(terms, "("+o.Column+" IS NULL) DESC")
+			} else {
+				terms = append(terms, "("+o.Column+" IS NULL)")
+			}
+		}
+		if o.Desc != prev {
+			terms = append(terms, o.Column+" DESC")
+		} else {
+			terms = append(terms, o.Column)
+		}
+	}
+	return strings.Join(terms, ", ")
+}
+
+// keysetCond returns the condition of the rows after vals in the order, or
+// before vals for the previous page. It is a row comparison, e.g.
+// (a, b) > (?, ?), when all columns are not nullable and in the same
+// direction, or else expanded to a > ? OR (a = ? AND b < ?).
+func keysetCond(order []PageOrder, vals []interface{}, prev bool) (string, []interface{}) {
+	op := func(o PageOrder) string {
+		if o.Desc != prev {
+			return "<"
+		}
+		return ">"
+	}
+
+	simple := true
+	for _, o := range order {
+		if o.Nullable || o.Desc != order[0].Desc {
+			simple = false
+			break
+		}
+	}
+	if simple {
+		if len(order) == 1 {
+			return order[0].Column + " " + op(order[0]) + " ?", vals
+		}
+		cols := strings.Join(pageColumns(order), ", ")
+		marks := "?" + strings.Repeat(", ?", len(order)-1)
+		return "(" + cols + ") " + op(order[0]) + " (" + marks + ")", vals
+	}
+
+	var (
+		ors    []string
+		args   []interface{}
+		eqs    []string
+		eqArgs []interface{}
+	)
+	for i, o := range order {
+		null := isNullValue(vals[i])
+
+		var after string
+		var afterArgs []interface{}
+		switch {
+		case !o.Nullable:
+			after, afterArgs = o.Column+" "+op(o)+" ?", []interface{}{vals[i]}
+		case null && !prev:
+			// NULLs are last, and nothing follows NULL.
+		case null && prev:
+			after = o.Column + " IS NOT NULL"
+		case !prev:
+			after, afterArgs = "("+o.Column+" "+op(o)+" ? OR "+o.Column+" IS NULL)", []interface{}{vals[i]}
+		default:
+			after, afterArgs = o.Column+" "+op(o)+" ?", []interface{}{vals[i]}
+		}
+		if after != "" {
+			ors = append(ors, strings.Join(append(append([]string{}, eqs...), after), " AND "))
+			args = append(append(args, eqArgs...), afterArgs...)
+		}
+
+		if null {
+			eqs = append(eqs, o.Column+" IS NULL")
+		} else {
+			eqs = append(eqs, o.Column+" = ?")
+			eqArgs = append(eqArgs, vals[i])
+		}
+	}
+	if len(ors) == 0 {
+		return "(1=0)", nil
+	}
+	for i, s := range ors {
+		ors[i] = "(" + s + ")"
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+func isNullValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return true
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		return err == nil && dv == nil
+	}
+	return false
+}
+
+func encodeCursor(row reflect.Value, cols []column, prev bool) (string, error) {
+	row = reflect.Indirect(row)
+	cur := cursor{Values: make([]json.RawMessage, len(cols)), Prev: prev}
+	for i, c := range cols {
+		b, err := json.Marshal(reflectx.FieldByIndexesReadOnly(row, c.index).Interface())
+		if err != nil {
+			return "", xerrors.Errorf("sqlxx: encode cursor: %w", err)
+		}
+		cur.Values[i] = b
+	}
+	b, err := json.Marshal(cur)
+	if err != nil {
+		return "", xerrors.Errorf("sqlxx: encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor decodes the values of cols into the types of the fields of
+// struct type t.
+func decodeCursor(s string, t reflect.Type, cols []column) (cursor, []interface{}, error) {
+	var cur cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, nil, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &cur); err != nil || len(cur.Values) != len(cols) {
+		return cur, nil, ErrInvalidCursor
+	}
+
+	t = reflectx.Deref(t)
+	vals := make([]interface{}, len(cols))
+	for i, c := range cols {
+		v := reflect.New(t.FieldByIndex(c.index).Type)
+		if err := json.Unmarshal(cur.Values[i], v.Interface()); err != nil {
+			return cur, nil, ErrInvalidCursor
+		}
+		vals[i] = v.Elem().Interface()
+	}
+	return cur, vals, nil
+}
@@ -0,0 +1,184 @@
+package sqlxx
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestKeysetCond(t *testing.T) {
+	tests := []struct {
+		name      string
+		order     []PageOrder
+		vals      []interface{}
+		prev      bool
+		wantCond  string
+		wantArgs  []interface{}
+		wantOrder string
+	}{
+		{
+			"single",
+			[]PageOrder{{Column: "id"}},
+			[]interface{}{1}, false,
+			"id > ?", []interface{}{1},
+			"id",
+		},
+		{
+			"row",
+			[]PageOrder{{Column: "a", Desc: true}, {Column: "id", Desc: true}},
+			[]interface{}{1, 2}, false,
+			"(a, id) < (?, ?)", []interface{}{1, 2},
+			"a DESC, id DESC",
+		},
+		{
+			"row prev",
+			[]PageOrder{{Column: "a"}, {Column: "id"}},
+			[]interface{}{1, 2}, true,
+			"(a, id) < (?, ?)", []interface{}{1, 2},
+			"a DESC, id DESC",
+		},
+		{
+			"mixed",
+			[]PageOrder{{Column: "a"}, {Column: "id", Desc: true}},
+			[]interface{}{1, 2}, false,
+			"((a > ?) OR (a = ? AND id < ?))", []interface{}{1, 1, 2},
+			"a, id DESC",
+		},
+		{
+			"nullable",
+			[]PageOrder{{Column: "a", Nullable: true}, {Column: "id"}},
+			[]interface{}{1, 2}, false,
+			"(((a > ? OR a IS NULL)) OR (a = ? AND id > ?))", []interface{}{1, 1, 2},
+			"(a IS NULL), a, id",
+		},
+		{
+			"nullable null",
+			[]PageOrder{{Column: "a", Nullable: true}, {Column: "id"}},
+			[]interface{}{(*int)(nil), 2}, false,
+			"((a IS NULL AND id > ?))", []interface{}{2},
+			"(a IS NULL), a, id",
+		},
+		{
+			"nullable prev",
+			[]PageOrder{{Column: "a", Nullable: true}, {Column: "id"}},
+			[]interface{}{nil, 2}, true,
+			"((a IS NOT NULL) OR (a IS NULL AND id < ?))", []interface{}{2},
+			"(a IS NULL) DESC, a DESC, id DESC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, args := keysetCond(tt.order, tt.vals, tt.prev)
+			if cond != tt.wantCond {
+				t.Errorf("want %q, got %q", tt.wantCond, cond)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("want %v, got %v", tt.wantArgs, args)
+			}
+			if got := keysetOrder(tt.order, tt.prev); got != tt.wantOrder {
+				t.Errorf("want %q, got %q", tt.wantOrder, got)
+			}
+		})
+	}
+}
+
+type pageUser struct {
+	ID    int64   `db:"id"`
+	Email string  `db:"email"`
+	Nick  *string `db:"nick"`
+}
+
+func TestPaginate(t *testing.T) {
+	errRollback := errors.New("rollback")
+	const query = "SELECT id, email, CASE WHEN MOD(id, 2) = 0 THEN NULL ELSE SUBSTRING(email, 1, 6) END AS nick FROM user WHERE email LIKE ?"
+	args := []interface{}{"page-%"}
+	opts := &PageOption{
+		Order: []PageOrder{{Column: "nick", Desc: true, Nullable: true}, {Column: "id"}},
+		Limit: 2,
+	}
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		for _, email := range []string{"page-a1@example.com", "page-b1@example.com", "page-a2@example.com", "page-c1@example.com", "page-b2@example.com", "page-a3@example.com"} {
+			if _, err := db.Exec(ctx, "INSERT INTO user (email, password) VALUES (?, ?)", email, testPassword); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var want []pageUser
+		if err := db.Select(ctx, &want, "SELECT * FROM ("+query+") AS t ORDER BY (nick IS NULL), nick DESC, id", args...); err != nil {
+			t.Fatal(err)
+		}
+
+		var (
+			got  []pageUser
+			last []pageUser
+			page = &Page{}
+		)
+		for i := 0; ; i++ {
+			var users []pageUser
+			o := *opts
+			o.Cursor = page.Next
+			p, err := db.Paginate(ctx, &users, query, args, &o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i == 0 && p.Prev != "" {
+				t.Error("want no previous page of the first page")
+			}
+			got = append(got, users...)
+			last = users
+			page = p
+			if p.Next == "" {
+				break
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("want %v, got %v", want, got)
+		}
+
+		// Walk back from the last page.
+		var back []pageUser
+		for page.Prev != "" {
+			var users []pageUser
+			o := *opts
+			o.Cursor = page.Prev
+			p, err := db.Paginate(ctx, &users, query, args, &o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Next == "" {
+				t.Error("want the next page of a previous page")
+			}
+			back = append(users, back...)
+			page = p
+		}
+		if w := want[:len(want)-len(last)]; !reflect.DeepEqual(back, w) {
+			t.Errorf("want %v, got %v", w, back)
+		}
+
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+}
+
+func TestPaginateError(t *testing.T) {
+	ctx := context.Background()
+	var users []pageUser
+
+	if _, err := db.Paginate(ctx, &users, "SELECT * FROM user", nil, &PageOption{}); err == nil {
+		t.Error("want non-nil error for no order")
+	}
+	if _, err := db.Paginate(ctx, users, "SELECT * FROM user", nil, &PageOption{Order: []PageOrder{{Column: "id"}}}); err == nil {
+		t.Error("want non-nil error for non-pointer")
+	}
+	if _, err := db.Paginate(ctx, &users, "SELECT * FROM user", nil, &PageOption{Order: []PageOrder{{Column: "x"}}}); err == nil {
+		t.Error("want non-nil error for unknown column")
+	}
+	if _, err := db.Paginate(ctx, &users, "SELECT * FROM user", nil, &PageOption{Order: []PageOrder{{Column: "id"}}, Cursor: "!"}); err != ErrInvalidCursor {
+		t.Errorf("want ErrInvalidCursor, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package sqlxx
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const DefaultHealthCheckInterval = 5 * time.Second
+
+type ReplicaPolicy int
+
+const (
+	// ReplicaRoundRobin picks the healthy replicas in turn.
+	ReplicaRoundRobin ReplicaPolicy = iota
+	// ReplicaLeastConn picks the healthy replica with the fewest connections in use.
+	ReplicaLeastConn
+)
+
+type ReplicaOption struct {
+	Policy ReplicaPolicy
+	// HealthCheckInterval is the interval at which every replica is pinged.
+	// A replica which fails the ping or a query with a connection error is
+	// ejected until it passes a ping again.
+	HealthCheckInterval time.Duration
+}
+
+type replica struct {
+	dbx     *sqlx.DB
+	healthy int32
+}
+
+type replicaSet struct {
+	replicas []*replica
+	policy   ReplicaPolicy
+	next     uint32
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// NewWithReplicas is like New, but routes Get, Select and Query outside a
+// transaction to one of replicas. Writes, queries in RunInTx and queries
+// with a context marked by UsePrimary go to primary, as do all queries
+// while every replica is unhealthy. Call Close to stop the health check.
+func NewWithReplicas(primary *sqlx.DB, replicas []*sqlx.DB, l Logger, opts *Option, ropts *ReplicaOption) *DB {
+	db := New(primary, l, opts)
+	if len(replicas) == 0 {
+		return db
+	}
+
+	interval := DefaultHealthCheckInterval
+	rs := &replicaSet{stop: make(chan struct{})}
+	if ropts != nil {
+		rs.policy = ropts.Policy
+		if ropts.HealthCheckInterval > 0 {
+			interval = ropts.HealthCheckInterval
+		}
+	}
+	for _, r := range replicas {
+		rs.replicas = append(rs.replicas, &replica{dbx: r, healthy: 1})
+	}
+	go rs.healthCheck(interval)
+
+	db.replicas = rs
+	return db
+}
+
+// UsePrimary returns a copy of ctx which routes reads to the primary, e.g.
+// to read the writes just made outside a transaction.
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usePrimaryCtxKey, true)
+}
+
+func isPrimaryCtx(ctx context.Context) bool {
+	v, _ := ctx.Value(usePrimaryCtxKey).(bool)
+	return v
+}
+
+// buildRead is like build, but returns a replica for reads outside a
+// transaction. The returned *replica is nil unless a replica is used.
+func (db *DB) buildRead(ctx context.Context) (queryer, *replica) {
+	if db.replicas == nil || db.txFromCtx(ctx) != nil || isPrimaryCtx(ctx) {
+		return db.build(ctx), nil
+	}
+	if r := db.replicas.pick(); r != nil {
+		return r.dbx, r
+	}
+	return db.dbx, nil
+}
+
+func (rs *replicaSet) pick() *replica {
+	switch rs.policy {
+	case ReplicaLeastConn:
+		var picked *replica
+		inUse := 0
+		for _, r := range rs.replicas {
+			if !r.isHealthy() {
+				continue
+			}
+			if n := r.dbx.Stats().InUse; picked == nil || n < inUse {
+				picked, inUse = r, n
+			}
+		}
+		return picked
+	default:
+		n := uint32(len(rs.replicas))
+		for i := uint32(0); i < n; i++ {
+			if r := rs.replicas[atomic.AddUint32(&rs.next, 1)%n]; r.isHealthy() {
+				return r
+			}
+		}
+		return nil
+	}
+}
+
+func (rs *replicaSet) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			for _, r := range rs.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				r.setHealthy(r.dbx.PingContext(ctx) == nil)
+				cancel()
+			}
+		}
+	}
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) setHealthy(ok bool) {
+	var v int32
+	if ok {
+		v = 1
+	}
+	atomic.StoreInt32(&r.healthy, v)
+}
+
+// check ejects r if err is a connection error, which the deadline or the
+// cancellation of ctx is not. r may be nil.
+func (r *replica) check(err error) {
+	if r != nil && IsConnectionError(err) {
+		r.setHealthy(false)
+	}
+}
@@ -0,0 +1,177 @@
+package sqlxx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/xerrors"
+)
+
+func newReplicas(t *testing.T, n int) []*sqlx.DB {
+	t.Helper()
+	var dbs []*sqlx.DB
+	for i := 0; i < n; i++ {
+		r, err := sqlx.Connect("mysql", testDSN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { r.Close() })
+		dbs = append(dbs, r)
+	}
+	return dbs
+}
+
+func TestReplicaRouting(t *testing.T) {
+	replicas := newReplicas(t, 1)
+	db := NewWithReplicas(dbx, replicas, nil, nil, nil)
+	defer db.Close()
+	ctx := context.Background()
+
+	if q, _ := db.buildRead(ctx); q != replicas[0] {
+		t.Errorf("want replica, got %v", q)
+	}
+	if q := db.build(ctx); q != dbx {
+		t.Errorf("writes: want primary, got %v", q)
+	}
+	if q, _ := db.buildRead(UsePrimary(ctx)); q != dbx {
+		t.Errorf("UsePrimary: want primary, got %v", q)
+	}
+
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+		if q, _ := db.buildRead(ctx); q != db.txFromCtx(ctx) {
+			t.Errorf("in tx: want tx, got %T", q)
+		}
+		_, _ = db.RunInTxWith(ctx, &TxOption{Propagation: PropagationNotSupported}, func(ctx context.Context) error {
+			if q, _ := db.buildRead(ctx); q != replicas[0] {
+				t.Errorf("tx suspended: want replica, got %v", q)
+			}
+			return nil
+		})
+		return nil
+	})
+
+	db.replicas.replicas[0].setHealthy(false)
+	if q, _ := db.buildRead(ctx); q != dbx {
+		t.Errorf("no healthy replica: want primary, got %v", q)
+	}
+
+	var n int
+	if err := db.Get(ctx, &n, "SELECT COUNT(*) FROM user;"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplicaRoundRobin(t *testing.T) {
+	replicas := newReplicas(t, 3)
+	db := NewWithReplicas(dbx, replicas, nil, nil, &ReplicaOption{Policy: ReplicaRoundRobin})
+	defer db.Close()
+	db.replicas.replicas[1].setHealthy(false)
+
+	got := map[*sqlx.DB]int{}
+	for i := 0; i < 6; i++ {
+		q, r := db.buildRead(context.Background())
+		if q != r.dbx {
+			t.Fatalf("want the picked replica, got %v", q)
+		}
+		got[r.dbx]++
+	}
+
+	if got[replicas[0]] != 3 || got[replicas[1]] != 0 || got[replicas[2]] != 3 {
+		t.Errorf("want 3, 0, 3 picks, got %d, %d, %d", got[replicas[0]], got[replicas[1]], got[replicas[2]])
+	}
+}
+
+func TestReplicaLeastConn(t *testing.T) {
+	replicas := newReplicas(t, 2)
+	db := NewWithReplicas(dbx, replicas, nil, nil, &ReplicaOption{Policy: ReplicaLeastConn})
+	defer db.Close()
+	ctx := context.Background()
+
+	conn, err := replicas[0].Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		if q, _ := db.buildRead(ctx); q != replicas[1] {
+			t.Errorf("want the replica with no connection in use, got %v", q)
+		}
+	}
+}
+
+func TestReplicaEject(t *testing.T) {
+	down, err := sqlx.Open("mysql", "sqlxxtester:Passw0rd!@tcp(127.0.0.1:1)/sqlxxtest?timeout=100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer down.Close()
+	replicas := append(newReplicas(t, 1), down)
+
+	db := NewWithReplicas(dbx, replicas, nil, nil, &ReplicaOption{HealthCheckInterval: 10 * time.Millisecond})
+	defer db.Close()
+	ctx := context.Background()
+
+	db.replicas.next = 0 // the next pick is down
+	var n int
+	if err := db.Get(ctx, &n, "SELECT COUNT(*) FROM user;"); err == nil {
+		t.Fatal("want connection error")
+	}
+	if db.replicas.replicas[1].isHealthy() {
+		t.Error("want ejected")
+	}
+	for i := 0; i < 3; i++ {
+		if err := db.Get(ctx, &n, "SELECT COUNT(*) FROM user;"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	up := db.replicas.replicas[0]
+	up.setHealthy(false)
+	deadline := time.Now().Add(time.Second)
+	for !up.isHealthy() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !up.isHealthy() {
+		t.Error("want recovered by the health check")
+	}
+	if db.replicas.replicas[1].isHealthy() {
+		t.Error("want still ejected")
+	}
+}
+
+func TestReplicaEjectTimeout(t *testing.T) {
+	db := NewWithReplicas(dbx, newReplicas(t, 1), nil, nil, &ReplicaOption{HealthCheckInterval: time.Hour})
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	var n int
+	if err := db.Get(ctx, &n, "SELECT COUNT(*) FROM user;"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+	if !db.replicas.replicas[0].isHealthy() {
+		t.Error("want not ejected by the deadline of the caller")
+	}
+
+	r := db.replicas.replicas[0]
+	for _, err := range []error{context.Canceled, xerrors.Errorf("wrapped: %w", context.DeadlineExceeded)} {
+		r.check(err)
+		if !r.isHealthy() {
+			t.Errorf("%v: want not ejected", err)
+		}
+	}
+}
+
+func TestReplicaNone(t *testing.T) {
+	db := NewWithReplicas(dbx, nil, nil, nil, nil)
+	if db.replicas != nil {
+		t.Error("want no replica set")
+	}
+	if err := db.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,111 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"time"
+)
+
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one.
+	MaxAttempts int
+	// Backoff is the wait before the first retry. It doubles on every retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter is the fraction of the backoff, between 0 and 1, that is randomized.
+	Jitter float64
+	// Retryable reports whether err is worth retrying. If nil, IsRetryable is used.
+	Retryable func(err error) bool
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     10 * time.Millisecond,
+	MaxBackoff:  time.Second,
+	Jitter:      0.5,
+}
+
+// RunInTxWithRetry is RunInTx retrying the whole transaction on errors that
+// policy considers retryable. If policy is nil, DefaultRetryPolicy is used.
+// Like RunInTx, it joins an existing transaction with its options as is.
+func (db *DB) RunInTxWithRetry(ctx context.Context, policy *RetryPolicy, txFn TxFunc) (err, rbErr error) {
+	if policy == nil {
+		policy = &DefaultRetryPolicy
+	}
+	return db.RunInTxWith(ctx, &TxOption{Retry: policy}, txFn)
+}
+
+// runInNewTxWithRetry retries only transactions begun here. Joined
+// transactions are never retried, so that the error reaches the outermost
+// RunInTx which replays the whole transaction.
+func (db *DB) runInNewTxWithRetry(ctx context.Context, opts *sql.TxOptions, policy *RetryPolicy, txFn TxFunc) (err, rbErr error) {
+	for attempt := 1; ; attempt++ {
+		err, rbErr = db.runInNewTx(ctx, opts, txFn)
+		if err == nil || rbErr != nil || policy == nil {
+			return err, rbErr
+		}
+		if attempt >= policy.MaxAttempts || !policy.retryable(err) {
+			return err, rbErr
+		}
+
+		d := policy.backoff(attempt)
+		db.logRetry(ctx, err, attempt, policy.MaxAttempts, d)
+
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err(), nil
+		case <-t.C:
+		}
+	}
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 && d > 0 {
+		j := time.Duration(float64(d) * p.Jitter)
+		if j > 0 {
+			d = d - j + time.Duration(rand.Int63n(int64(j)+1))
+		}
+	}
+
+	return d
+}
+
+func (db *DB) logRetry(ctx context.Context, err error, attempt, maxAttempts int, d time.Duration) {
+	if db.slogger != nil {
+		db.slogger.Log(ctx, LogEvent{Level: LevelWarn, Command: CmdRetry, Duration: d, Err: err, Attempt: attempt})
+		return
+	}
+	if db.logger == nil {
+		return
+	}
+	db.logger.Warnf(ctx, "[%s] %s [%d/%d attempts] [%.2f ms backoff]", CmdRetry, err.Error(), attempt, maxAttempts, toMillisec(d))
+}
+
+// IsRetryable reports whether err is a deadlock, a lock timeout or a
+// serialization failure after which replaying the transaction may succeed.
+// See KindOf for the error codes.
+func IsRetryable(err error) bool {
+	switch kind, _ := KindOf(err); kind {
+	case ErrorDeadlock, ErrorLockTimeout, ErrorSerialization:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package sqlxx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/xerrors"
+)
+
+type sqlStateErr string
+
+func (e sqlStateErr) Error() string    { return "sql state " + string(e) }
+func (e sqlStateErr) SQLState() string { return string(e) }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("some error"), false},
+		{&mysql.MySQLError{Number: 1213}, true},
+		{&mysql.MySQLError{Number: 1205}, true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{xerrors.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1213}), true},
+		{sqlStateErr("40001"), true},
+		{sqlStateErr("40P01"), true},
+		{sqlStateErr("55P03"), true},
+		{sqlStateErr("23505"), false},
+		{xerrors.Errorf("wrapped: %w", sqlStateErr("40P01")), true},
+	}
+
+	for i, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("#%d: want %t, got %t", i, tt.want, got)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{RetryPolicy{Backoff: 10 * time.Millisecond}, 1, 10 * time.Millisecond},
+		{RetryPolicy{Backoff: 10 * time.Millisecond}, 2, 20 * time.Millisecond},
+		{RetryPolicy{Backoff: 10 * time.Millisecond}, 4, 80 * time.Millisecond},
+		{RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}, 4, 50 * time.Millisecond},
+		{RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}, 100, 50 * time.Millisecond},
+	}
+
+	for i, tt := range tests {
+		if got := tt.policy.backoff(tt.attempt); got != tt.want {
+			t.Errorf("#%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(1); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("want between 50ms and 100ms, got %v", got)
+		}
+	}
+}
+
+func TestRunInTxWithRetry(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	policy := &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+		wantLogs  int
+	}{
+		{"success", []error{nil}, nil, 1, 0},
+		{"retry and success", []error{deadlock, deadlock, nil}, nil, 3, 2},
+		{"max attempts", []error{deadlock, deadlock, deadlock, nil}, deadlock, 3, 2},
+		{"not retryable", []error{ErrTxExists, nil}, ErrTxExists, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			db := New(dbx, NewLogger(&buf), nil)
+
+			var calls int
+			err, rbErr := db.RunInTxWithRetry(context.Background(), policy, func(ctx context.Context) error {
+				calls++
+				return tt.errs[calls-1]
+			})
+
+			if rbErr != nil {
+				t.Fatal(rbErr)
+			}
+			if err != tt.wantErr {
+				t.Errorf("want %v, got %v", tt.wantErr, err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls: want %d, got %d", tt.wantCalls, calls)
+			}
+			if got := strings.Count(buf.String(), "[WARN]"); got != tt.wantLogs {
+				t.Errorf("logs: want %d, got %d\n%s", tt.wantLogs, got, buf.String())
+			}
+			if tt.wantLogs > 0 && !strings.Contains(buf.String(), "[RETRY] "+deadlock.Error()+" [1/3 attempts]") {
+				t.Errorf("wrong log: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestRunInTxWithRetryNested(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	policy := &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	var outer, inner int
+	err, rbErr := db.RunInTxWithRetry(context.Background(), policy, func(ctx context.Context) error {
+		outer++
+		err, _ := db.RunInTxWithRetry(ctx, policy, func(ctx context.Context) error {
+			inner++
+			if outer < 2 {
+				return deadlock // joined, so retried by the outermost
+			}
+			return nil
+		})
+		return err
+	})
+
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outer != 2 || inner != 2 {
+		t.Errorf("want 2 outer and 2 inner calls, got %d and %d", outer, inner)
+	}
+
+	readOnly := true
+	var called bool
+	err, rbErr = db.RunInTxWith(context.Background(), &TxOption{ReadOnly: &readOnly}, func(ctx context.Context) error {
+		err, _ := db.RunInTxWithRetry(ctx, policy, func(ctx context.Context) error {
+			called = true // joins the read-only transaction
+			return nil
+		})
+		return err
+	})
+
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("want nested call in read-only transaction")
+	}
+}
+
+func TestRunInTxWithRetryCancel(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	policy := &RetryPolicy{MaxAttempts: 3, Backoff: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	err, _ := db.RunInTxWithRetry(ctx, policy, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return deadlock
+	})
+
+	if err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls: want 1, got %d", calls)
+	}
+}
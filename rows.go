@@ -0,0 +1,46 @@
+package sqlxx
+
+import (
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Rows wraps *sqlx.Rows returned by DB.Query. It counts rows as the caller
+// iterates them, and finishes the query, e.g. logs it with the row count and
+// the total elapsed time, once, when the rows are exhausted or closed.
+type Rows struct {
+	*sqlx.Rows
+
+	info *QueryInfo
+	n    int
+	once sync.Once
+}
+
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		r.n++
+		return true
+	}
+	r.finish(r.Rows.Err())
+	return false
+}
+
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	if rowsErr := r.Rows.Err(); rowsErr != nil {
+		r.finish(rowsErr)
+	} else {
+		r.finish(err)
+	}
+	return err
+}
+
+func (r *Rows) finish(err error) {
+	r.once.Do(func() {
+		if r.info != nil {
+			r.info.Rows = r.n
+			r.info.finish(err)
+		}
+	})
+}
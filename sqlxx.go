@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -14,9 +15,15 @@ import (
 type DB struct {
 	dbx          *sqlx.DB
 	logger       Logger
+	slogger      StructuredLogger
+	interceptors *interceptors
+	replicas     *replicaSet
+	stmts        *stmtCache
 	warnDuration time.Duration
 	warnRows     int
 	hideParams   bool
+	expandIn     bool
+	wrapErrors   bool
 }
 
 const (
@@ -24,11 +31,16 @@ const (
 	DefaultWarnRows     = 1000
 	DefaultHideParams   = false
 
-	CmdGet       = "GET"
-	CmdSelect    = "SELECT"
-	CmdQuery     = "QUERY"
-	CmdExec      = "EXEC"
-	CmdNamedExec = "N-EXEC"
+	CmdGet         = "GET"
+	CmdSelect      = "SELECT"
+	CmdQuery       = "QUERY"
+	CmdExec        = "EXEC"
+	CmdNamedExec   = "N-EXEC"
+	CmdNamedGet    = "N-GET"
+	CmdNamedSelect = "N-SELECT"
+	CmdNamedQuery  = "N-QUERY"
+	CmdEach        = "EACH"
+	CmdRetry       = "RETRY"
 )
 
 type Option struct {
@@ -54,82 +66,273 @@ func New(db *sqlx.DB, l Logger, opts *Option) *DB {
 		hideParams = DefaultHideParams
 	}
 
-	return &DB{db, l, warnDuration, warnRows, hideParams}
+	return &DB{
+		dbx:          db,
+		logger:       l,
+		warnDuration: warnDuration,
+		warnRows:     warnRows,
+		hideParams:   hideParams,
+	}
 }
 
 type ctxKey string
 
 const (
-	txCtxKey ctxKey = "tx-ctx-key"
+	txCtxKey         ctxKey = "tx-ctx-key"
+	usePrimaryCtxKey ctxKey = "use-primary-ctx-key"
 )
 
+// dbTxCtxKey keys the transaction of each underlying *sqlx.DB, so that a
+// transaction begun on one database is never used by another. txCtxKey holds
+// the innermost transaction of any database.
+type dbTxCtxKey struct{ dbx *sqlx.DB }
+
+type txState struct {
+	tx    *sqlx.Tx
+	opts  sql.TxOptions
+	depth int
+	hooks *txHooks
+}
+
 type queryer interface {
 	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
 	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
-	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
 }
 
 func (db *DB) build(ctx context.Context) queryer {
-	if tx, ok := ctx.Value(txCtxKey).(*sqlx.Tx); ok {
+	if tx := db.txFromCtx(ctx); tx != nil {
 		return tx
 	}
 	return db.dbx
 }
 
-func newTxCtx(ctx context.Context, tx *sqlx.Tx) context.Context {
-	return context.WithValue(ctx, txCtxKey, tx)
+func (db *DB) newTxCtx(ctx context.Context, tx *sqlx.Tx) context.Context {
+	return db.newTxStateCtx(ctx, &txState{tx: tx})
 }
 
-func (db *DB) Query(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
-	start := time.Now()
-	rows, err := db.build(ctx).QueryxContext(ctx, query, args...)
-	clone := *rows
-	db.log(ctx, CmdQuery, query, args, err, countRows(&clone), time.Since(start))
-	return rows, err
+func (db *DB) newTxStateCtx(ctx context.Context, st *txState) context.Context {
+	ctx = context.WithValue(ctx, dbTxCtxKey{db.dbx}, st)
+	return context.WithValue(ctx, txCtxKey, st)
 }
 
-func (db *DB) Get(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+func (db *DB) txState(ctx context.Context) *txState {
+	st, _ := ctx.Value(dbTxCtxKey{db.dbx}).(*txState)
+	return st
+}
+
+func (db *DB) txFromCtx(ctx context.Context) *sqlx.Tx {
+	if st := db.txState(ctx); st != nil {
+		return st.tx
+	}
+	return nil
+}
+
+func (db *DB) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	info := &QueryInfo{Command: CmdQuery, Query: query, Args: args}
+	return db.query(ctx, info, func(ctx context.Context, q queryer) (*sqlx.Rows, error) {
+		return q.QueryxContext(ctx, info.Query, info.Args...)
+	})
+}
+
+// Each runs query and calls fn for each row until the rows are exhausted or
+// fn returns an error, which is returned. The rows are always closed, and
+// the query is logged once with the row count and the total elapsed time.
+func (db *DB) Each(ctx context.Context, query string, args []interface{}, fn func(*Rows) error) error {
 	start := time.Now()
-	err := db.build(ctx).GetContext(ctx, dest, query, args...)
-	rows := 1
+	info := &QueryInfo{Command: CmdEach, Query: query, Args: args}
+	rows, err := db.query(ctx, info, func(ctx context.Context, q queryer) (*sqlx.Rows, error) {
+		return q.QueryxContext(ctx, info.Query, info.Args...)
+	})
 	if err != nil {
-		rows = 0
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			rows.finish(err) // finish with the callback error, not the nil one of Close
+			return err
+		}
+	}
+	err = rows.Err()
+	if err == nil {
+		err = rows.Close()
+	}
+	if err != nil && db.wrapErrors {
+		err = db.queryError(info, err, time.Since(start))
 	}
-	db.log(ctx, CmdGet, query, args, err, rows, time.Since(start))
 	return err
 }
 
+func (db *DB) query(ctx context.Context, info *QueryInfo, open func(context.Context, queryer) (*sqlx.Rows, error)) (*Rows, error) {
+	var r *Rows
+	err := db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) error {
+		q, rep := db.buildRead(ctx)
+		q = db.cached(q, info)
+		rows, err := open(ctx, q)
+		rep.check(err)
+		if err != nil {
+			return err
+		}
+		r = &Rows{Rows: rows, info: info}
+		info.streaming = true
+		return nil
+	})
+	if err != nil {
+		if r != nil {
+			_ = r.Rows.Close()
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+func (db *DB) Get(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	info := &QueryInfo{Command: CmdGet, Query: query, Args: args}
+	return db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) error {
+		q, rep := db.buildRead(ctx)
+		q = db.cached(q, info)
+		err := q.GetContext(ctx, dest, info.Query, info.Args...)
+		rep.check(err)
+		if err == nil {
+			info.Rows = 1
+		}
+		return err
+	})
+}
+
 func (db *DB) Select(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	start := time.Now()
-	err := db.build(ctx).SelectContext(ctx, dest, query, args...)
-	db.log(ctx, CmdSelect, query, args, err, countRows(dest), time.Since(start))
-	return err
+	info := &QueryInfo{Command: CmdSelect, Query: query, Args: args}
+	return db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) error {
+		q, rep := db.buildRead(ctx)
+		q = db.cached(q, info)
+		err := q.SelectContext(ctx, dest, info.Query, info.Args...)
+		rep.check(err)
+		info.Rows = countRows(dest)
+		return err
+	})
 }
 
 func (db *DB) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	start := time.Now()
-	res, err := db.build(ctx).ExecContext(ctx, query, args...)
-	db.log(ctx, CmdExec, query, args, err, countRows(res), time.Since(start))
+	var res sql.Result
+	info := &QueryInfo{Command: CmdExec, Query: query, Args: args}
+	err := db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) (err error) {
+		res, err = db.cached(db.build(ctx), info).ExecContext(ctx, info.Query, info.Args...)
+		info.Rows = countRows(res)
+		return err
+	})
 	return res, err
 }
 
 func (db *DB) NamedExec(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
-	start := time.Now()
-	res, err := db.build(ctx).NamedExecContext(ctx, query, arg)
+	var res sql.Result
 	_, args, _ := sqlx.BindNamed(sqlx.NAMED, query, arg)
-	db.log(ctx, CmdNamedExec, query, args, err, countRows(res), time.Since(start))
+	info := &QueryInfo{Command: CmdNamedExec, Query: query, Args: args, named: true, arg: arg}
+	err := db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) (err error) {
+		query, args, err := db.bindNamed(info)
+		if err != nil {
+			return err
+		}
+		res, err = db.cached(db.build(ctx), info).ExecContext(ctx, query, args...)
+		info.Rows = countRows(res)
+		return err
+	})
 	return res, err
 }
 
+func (db *DB) NamedQuery(ctx context.Context, query string, arg interface{}) (*Rows, error) {
+	_, args, _ := sqlx.BindNamed(sqlx.NAMED, query, arg)
+	info := &QueryInfo{Command: CmdNamedQuery, Query: query, Args: args, named: true, arg: arg}
+	return db.query(ctx, info, func(ctx context.Context, q queryer) (*sqlx.Rows, error) {
+		query, args, err := db.bindNamed(info)
+		if err != nil {
+			return nil, err
+		}
+		return q.QueryxContext(ctx, query, args...)
+	})
+}
+
+func (db *DB) NamedGet(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	_, args, _ := sqlx.BindNamed(sqlx.NAMED, query, arg)
+	info := &QueryInfo{Command: CmdNamedGet, Query: query, Args: args, named: true, arg: arg}
+	return db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) error {
+		query, args, err := db.bindNamed(info)
+		if err != nil {
+			return err
+		}
+		q, rep := db.buildRead(ctx)
+		q = db.cached(q, info)
+		err = q.GetContext(ctx, dest, query, args...)
+		rep.check(err)
+		if err == nil {
+			info.Rows = 1
+		}
+		return err
+	})
+}
+
+func (db *DB) NamedSelect(ctx context.Context, dest interface{}, query string, arg interface{}) error {
+	_, args, _ := sqlx.BindNamed(sqlx.NAMED, query, arg)
+	info := &QueryInfo{Command: CmdNamedSelect, Query: query, Args: args, named: true, arg: arg}
+	return db.intercept(ctx, info, func(ctx context.Context, info *QueryInfo) error {
+		query, args, err := db.bindNamed(info)
+		if err != nil {
+			return err
+		}
+		q, rep := db.buildRead(ctx)
+		q = db.cached(q, info)
+		err = q.SelectContext(ctx, dest, query, args...)
+		rep.check(err)
+		info.Rows = countRows(dest)
+		return err
+	})
+}
+
+// bindNamed returns the query and args of a named command to execute,
+// which are already bound if IN expansion is enabled.
+func (db *DB) bindNamed(info *QueryInfo) (string, []interface{}, error) {
+	if info.bound {
+		return info.Query, info.Args, nil
+	}
+	return db.dbx.BindNamed(info.Query, info.arg)
+}
+
+func (db *DB) logInterceptor(ctx context.Context, info *QueryInfo, next Handler) error {
+	if isTxCommand(info.Command) || db.logger == nil && db.slogger == nil {
+		return next(ctx, info)
+	}
+
+	start := time.Now()
+	err := next(ctx, info)
+	info.OnFinish(func(err error) {
+		db.logQuery(ctx, info, err, time.Since(start))
+	})
+	return err
+}
+
 func (db *DB) log(ctx context.Context, cmd string, query string, args []interface{}, err error, rows int, d time.Duration) {
+	db.logQuery(ctx, &QueryInfo{Command: cmd, Query: query, Args: args, Rows: rows}, err, d)
+}
+
+func (db *DB) logQuery(ctx context.Context, info *QueryInfo, err error, d time.Duration) {
+	if db.slogger != nil {
+		ev := db.makeLogEvent(ctx, info.Command, info.Query, info.Args, info.Rows, err, d)
+		ev.StmtCache = info.StmtCache
+		db.slogger.Log(ctx, ev)
+		return
+	}
+
 	if db.logger == nil {
 		return
 	}
 
-	fn := db.loggerFunc(err, rows, d)
-	msg := db.makeLogMsg(cmd, query, args, rows, err, d)
+	fn := db.loggerFunc(err, info.Rows, d)
+	msg := db.makeLogMsg(info.Command, info.Query, info.Args, info.Rows, err, d)
+	if info.StmtCache != "" {
+		msg += " [stmt " + info.StmtCache + "]"
+	}
 
 	fn(ctx, msg)
 }
@@ -139,15 +342,45 @@ func (db *DB) loggerFunc(err error, rows int, d time.Duration) loggerFunc {
 		return nil
 	}
 
-	if err != nil && err != sql.ErrNoRows {
+	if db.logLevel(err, rows, d) == LevelWarn {
 		return db.logger.Warnf
+	}
+
+	return db.logger.Debugf
+}
+
+func (db *DB) logLevel(err error, rows int, d time.Duration) LogLevel {
+	if err != nil && err != sql.ErrNoRows {
+		return LevelWarn
 	} else if rows > db.warnRows {
-		return db.logger.Warnf
+		return LevelWarn
 	} else if d > db.warnDuration {
-		return db.logger.Warnf
+		return LevelWarn
 	}
 
-	return db.logger.Debugf
+	return LevelDebug
+}
+
+func (db *DB) makeLogEvent(ctx context.Context, cmd string, query string, args []interface{}, rows int, err error, elapsed time.Duration) LogEvent {
+	ev := LogEvent{
+		Level:    db.logLevel(err, rows, elapsed),
+		Command:  cmd,
+		Query:    query,
+		Rows:     rows,
+		Duration: elapsed,
+		Err:      err,
+		InTx:     db.IsInTx(ctx),
+	}
+	if query != "" {
+		ev.Fingerprint = db.queryFingerprint(query)
+	}
+	if !db.hideParams {
+		ev.Args = args
+		if ev.Args == nil {
+			ev.Args = []interface{}{}
+		}
+	}
+	return ev
 }
 
 func (db *DB) makeLogMsg(cmd string, query string, args []interface{}, rows int, err error, elapsed time.Duration) string {
@@ -170,15 +403,128 @@ func (db *DB) makeLogMsg(cmd string, query string, args []interface{}, rows int,
 		writeArgs(&b, args)
 	}
 
+	if query != "" {
+		b.WriteString(" [fp " + FingerprintHash(db.queryFingerprint(query)) + "]")
+	}
+
 	return b.String()
 }
 
+// ExpandIn returns a copy of db which expands slice arguments into the
+// "IN (?)" of the query like sqlx.In, and rebinds the query for the driver.
+// Named queries are bound before the expansion, so the query and args passed
+// to the interceptors and logged are the expanded ones.
+func (db *DB) ExpandIn() *DB {
+	clone := db.clone()
+	clone.expandIn = true
+	return clone
+}
+
+func (db *DB) inInterceptor(ctx context.Context, info *QueryInfo, next Handler) error {
+	if !db.expandIn || isTxCommand(info.Command) {
+		return next(ctx, info)
+	}
+
+	query, args := info.Query, info.Args
+	if info.named {
+		var err error
+		if query, args, err = sqlx.BindNamed(sqlx.QUESTION, query, info.arg); err != nil {
+			return err
+		}
+	}
+	query, args, err := expandIn(query, args)
+	if err != nil {
+		return err
+	}
+
+	info.Query, info.Args = db.dbx.Rebind(query), args
+	info.bound = info.named
+	return next(ctx, info)
+}
+
+// WrapErrors returns a copy of db which wraps the errors of the queries in
+// *QueryError.
+func (db *DB) WrapErrors() *DB {
+	clone := db.clone()
+	clone.wrapErrors = true
+	return clone
+}
+
+// expandIn expands the slice args into their ? in query like sqlx.In, but
+// passes the other args as they are, including nil and driver.Valuer, on
+// which sqlx.In panics.
+func expandIn(query string, args []interface{}) (string, []interface{}, error) {
+	hasList := false
+	for _, arg := range args {
+		if isListArg(arg) {
+			hasList = true
+			break
+		}
+	}
+	if !hasList {
+		return query, args, nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(query))
+	expanded := make([]interface{}, 0, len(args))
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			b.WriteByte(query[i])
+			continue
+		}
+		if n >= len(args) {
+			return "", nil, xerrors.Errorf("sqlxx: number of bindVars exceeds arguments")
+		}
+		arg := args[n]
+		n++
+		if !isListArg(arg) {
+			b.WriteByte('?')
+			expanded = append(expanded, arg)
+			continue
+		}
+		v := reflect.ValueOf(arg)
+		if v.Len() == 0 {
+			return "", nil, xerrors.Errorf("sqlxx: empty slice passed to 'in' query")
+		}
+		b.WriteString("?" + strings.Repeat(", ?", v.Len()-1))
+		for j := 0; j < v.Len(); j++ {
+			expanded = append(expanded, v.Index(j).Interface())
+		}
+	}
+	if n != len(args) {
+		return "", nil, xerrors.Errorf("sqlxx: number of bindVars less than number arguments")
+	}
+	return b.String(), expanded, nil
+}
+
 func (db *DB) Secret() *DB {
 	clone := db.clone()
 	clone.hideParams = true
 	return clone
 }
 
+// WithStructuredLogger returns a copy of db which logs LogEvent to l instead
+// of the Logger given to New.
+func (db *DB) WithStructuredLogger(l StructuredLogger) *DB {
+	clone := db.clone()
+	clone.slogger = l
+	return clone
+}
+
+// Close stops the health check of the replicas and closes the cached
+// statements. It does not close the *sqlx.DB given to the constructor.
+func (db *DB) Close() error {
+	if db.replicas != nil {
+		db.replicas.once.Do(func() { close(db.replicas.stop) })
+	}
+	if db.stmts != nil {
+		db.stmts.close()
+	}
+	return nil
+}
+
 func (db *DB) clone() *DB {
 	cloneDB := *db
 	return &cloneDB
@@ -186,35 +532,239 @@ func (db *DB) clone() *DB {
 
 type TxFunc func(context.Context) error
 
+type Propagation int
+
+const (
+	// PropagationRequired joins the transaction in the context, or begins a new one if there is none.
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew suspends the transaction in the context and always begins a new one.
+	PropagationRequiresNew
+	// PropagationMandatory joins the transaction in the context, or fails with ErrTxRequired.
+	PropagationMandatory
+	// PropagationNever runs without a transaction, or fails with ErrTxExists.
+	PropagationNever
+	// PropagationSupports joins the transaction in the context, or runs without one.
+	PropagationSupports
+	// PropagationNotSupported suspends the transaction in the context and runs without one.
+	PropagationNotSupported
+)
+
+var (
+	ErrTxRequired     = xerrors.New("sqlxx: transaction required")
+	ErrTxExists       = xerrors.New("sqlxx: transaction already exists")
+	ErrIncompatibleTx = xerrors.New("sqlxx: incompatible transaction options")
+)
+
+// TxOption configures RunInTxWith. Isolation and ReadOnly are applied when a
+// new transaction begins, and are checked against the existing transaction
+// when joining one. Options left unset (LevelDefault, nil ReadOnly) are not
+// checked, so a nil *TxOption inherits the existing transaction as is.
+// Retry is applied only to transactions begun by the call.
+type TxOption struct {
+	Propagation Propagation
+	Isolation   sql.IsolationLevel
+	ReadOnly    *bool
+	Retry       *RetryPolicy
+}
+
 func (db *DB) RunInTx(ctx context.Context, txFn TxFunc) (err, rbErr error) {
-	if IsInTx(ctx) {
+	return db.RunInTxWith(ctx, nil, txFn)
+}
+
+func (db *DB) RunInTxWith(ctx context.Context, opts *TxOption, txFn TxFunc) (err, rbErr error) {
+	var (
+		prop   Propagation
+		txOpts *sql.TxOptions
+		retry  *RetryPolicy
+	)
+	if opts != nil {
+		prop = opts.Propagation
+		txOpts = &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly != nil && *opts.ReadOnly}
+		retry = opts.Retry
+	}
+
+	st := db.txState(ctx)
+	if st != nil && st.tx == nil {
+		st = nil
+	}
+
+	switch prop {
+	case PropagationRequired:
+		if st != nil {
+			return db.joinTx(ctx, st, opts, txFn)
+		}
+		return db.runInNewTxWithRetry(ctx, txOpts, retry, txFn)
+	case PropagationRequiresNew:
+		return db.runInNewTxWithRetry(ctx, txOpts, retry, txFn)
+	case PropagationMandatory:
+		if st == nil {
+			return ErrTxRequired, nil
+		}
+		return db.joinTx(ctx, st, opts, txFn)
+	case PropagationNever:
+		if st != nil {
+			return ErrTxExists, nil
+		}
 		return txFn(ctx), nil
+	case PropagationSupports:
+		if st != nil {
+			return db.joinTx(ctx, st, opts, txFn)
+		}
+		return txFn(ctx), nil
+	case PropagationNotSupported:
+		return txFn(db.newTxCtx(ctx, nil)), nil
 	}
 
-	tx, err := db.dbx.Beginx()
+	return xerrors.Errorf("sqlxx: unknown propagation: %d", prop), nil
+}
+
+func (db *DB) runInNewTx(ctx context.Context, opts *sql.TxOptions, txFn TxFunc) (err, rbErr error) {
+	err = db.intercept(ctx, &QueryInfo{Command: CmdTx, TxOptions: opts}, func(ctx context.Context, info *QueryInfo) error {
+		err, rbErr = db.runTx(ctx, info, txFn)
+		return err
+	})
+	return err, rbErr
+}
+
+func (db *DB) runTx(ctx context.Context, info *QueryInfo, txFn TxFunc) (err, rbErr error) {
+	opts := info.TxOptions
+	var tx *sqlx.Tx
+	err = db.intercept(ctx, &QueryInfo{Command: CmdBegin}, func(ctx context.Context, _ *QueryInfo) (err error) {
+		tx, err = db.dbx.BeginTxx(ctx, opts)
+		return err
+	})
 	if err != nil {
+		if tx != nil {
+			_ = tx.Rollback() // begun, but failed by an interceptor
+		}
 		return err, nil
 	}
+
+	hooks := &txHooks{}
+	st := &txState{tx: tx, hooks: hooks}
+	if opts != nil {
+		st.opts = *opts
+	}
+	txCtx := db.newTxStateCtx(ctx, st)
+
 	defer func() {
 		if pnc := recover(); pnc != nil {
-			rbErr = tx.Rollback()
-			if pncErr, ok := pnc.(error); ok {
-				err = pncErr
-			} else {
-				err = xerrors.Errorf("sqlxx: recovered: %v", pnc)
-			}
+			rbErr = db.rollbackTx(txCtx, tx)
+			err = recoveredErr(pnc)
+			info.Panicked = true
 		} else if err != nil {
-			rbErr = tx.Rollback()
-		} else if cmtErr := tx.Commit(); cmtErr != nil && cmtErr != sql.ErrTxDone {
+			rbErr = db.rollbackTx(txCtx, tx)
+		} else if cmtErr := db.commitTx(txCtx, tx); cmtErr != nil && cmtErr != sql.ErrTxDone {
+			_ = tx.Rollback() // in case an interceptor failed the commit without running it
 			err = cmtErr
+		} else if cmtErr == sql.ErrTxDone && ctx.Err() != nil {
+			err = ctx.Err() // rolled back by database/sql when ctx was done
 		}
+		hooks.run(ctx, err == nil)
 	}()
 
-	err = txFn(newTxCtx(ctx, tx))
+	err = txFn(txCtx)
 	return
 }
 
+func (db *DB) commitTx(ctx context.Context, tx *sqlx.Tx) error {
+	return db.intercept(ctx, &QueryInfo{Command: CmdCommit}, func(context.Context, *QueryInfo) error {
+		return tx.Commit()
+	})
+}
+
+// rollbackTx ignores sql.ErrTxDone caused by ctx being done, because
+// database/sql has already rolled back the transaction in that case.
+func (db *DB) rollbackTx(ctx context.Context, tx *sqlx.Tx) error {
+	return db.intercept(ctx, &QueryInfo{Command: CmdRollback}, func(ctx context.Context, _ *QueryInfo) error {
+		if err := tx.Rollback(); err != nil && !(err == sql.ErrTxDone && ctx.Err() != nil) {
+			return err
+		}
+		return nil
+	})
+}
+
+func (db *DB) joinTx(ctx context.Context, st *txState, opts *TxOption, txFn TxFunc) (err, rbErr error) {
+	if err := checkTxOptions(st.opts, opts); err != nil {
+		return err, nil
+	}
+	return db.runInSavepoint(ctx, st, txFn)
+}
+
+func checkTxOptions(cur sql.TxOptions, opts *TxOption) error {
+	if opts == nil {
+		return nil
+	}
+	if opts.ReadOnly != nil && cur.ReadOnly && !*opts.ReadOnly {
+		return xerrors.Errorf("read-write block in read-only transaction: %w", ErrIncompatibleTx)
+	}
+	if opts.Isolation != sql.LevelDefault && opts.Isolation != cur.Isolation {
+		return xerrors.Errorf("%s block in %s transaction: %w", opts.Isolation, cur.Isolation, ErrIncompatibleTx)
+	}
+	return nil
+}
+
+// runInSavepoint runs txFn inside a savepoint of the transaction already
+// stored in ctx, so that a failing nested block can be undone without
+// aborting the outer transaction.
+func (db *DB) runInSavepoint(ctx context.Context, st *txState, txFn TxFunc) (err, rbErr error) {
+	err = db.intercept(ctx, &QueryInfo{Command: CmdSavepoint}, func(ctx context.Context, info *QueryInfo) error {
+		err, rbErr = db.runSavepoint(ctx, info, st, txFn)
+		return err
+	})
+	return err, rbErr
+}
+
+func (db *DB) runSavepoint(ctx context.Context, info *QueryInfo, st *txState, txFn TxFunc) (err, rbErr error) {
+	nested := *st
+	nested.depth++
+	tx, sp := st.tx, savepointName(nested.depth)
+
+	if _, err = tx.ExecContext(ctx, "SAVEPOINT "+sp); err != nil {
+		return err, nil
+	}
+	hooks := st.hooks
+	var mark txHooksMark
+	if hooks != nil {
+		mark = hooks.mark()
+	}
+	defer func() {
+		if pnc := recover(); pnc != nil {
+			_, rbErr = tx.Exec("ROLLBACK TO SAVEPOINT " + sp)
+			err = recoveredErr(pnc)
+			info.Panicked = true
+		} else if err != nil {
+			_, rbErr = tx.Exec("ROLLBACK TO SAVEPOINT " + sp)
+		} else if _, relErr := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp); relErr != nil {
+			err = relErr
+		}
+		if err != nil && hooks != nil {
+			hooks.rollbackTo(ctx, mark)
+		}
+	}()
+
+	err = txFn(db.newTxStateCtx(ctx, &nested))
+	return
+}
+
+func savepointName(depth int) string {
+	return fmt.Sprintf("sqlxx_sp_%d", depth)
+}
+
+func recoveredErr(pnc interface{}) error {
+	if pncErr, ok := pnc.(error); ok {
+		return pncErr
+	}
+	return xerrors.Errorf("sqlxx: recovered: %v", pnc)
+}
+
+// IsInTx reports whether ctx is in a transaction of any DB.
+// Use (*DB).IsInTx to check the transaction of a specific DB.
 func IsInTx(ctx context.Context) bool {
-	tx, ok := ctx.Value(txCtxKey).(*sqlx.Tx)
-	return ok && tx != nil
+	st, _ := ctx.Value(txCtxKey).(*txState)
+	return st != nil && st.tx != nil
+}
+
+func (db *DB) IsInTx(ctx context.Context) bool {
+	return db.txFromCtx(ctx) != nil
 }
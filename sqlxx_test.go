@@ -17,6 +17,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/go-cmp/cmp"
 	"github.com/jmoiron/sqlx"
+	"golang.org/x/xerrors"
 )
 
 /*
@@ -59,6 +60,8 @@ drop table if exists session;
 
 const testPassword = "Passw0rd!"
 
+const testDSN = "sqlxxtester:Passw0rd!@tcp(127.0.0.1:3306)/sqlxxtest?collation=utf8mb4_bin&interpolateParams=true&parseTime=true&maxAllowedPacket=0"
+
 var (
 	dbx *sqlx.DB
 	db  *DB
@@ -124,7 +127,7 @@ func getSessionByID(ctx context.Context, db *DB, id string) (Session, error) {
 func TestMain(m *testing.M) {
 
 	var err error
-	dbx, err = sqlx.Connect("mysql", "sqlxxtester:Passw0rd!@tcp(127.0.0.1:3306)/sqlxxtest?collation=utf8mb4_bin&interpolateParams=true&parseTime=true&maxAllowedPacket=0")
+	dbx, err = sqlx.Connect("mysql", testDSN)
 	if err != nil {
 		log.Fatalf("sqlx.Connect: %v", err)
 	}
@@ -183,7 +186,7 @@ func TestContext(t *testing.T) {
 	}()
 
 	ctx := context.Background()
-	txCtx := newTxCtx(ctx, tx)
+	txCtx := db.newTxCtx(ctx, tx)
 
 	var q queryer
 
@@ -255,7 +258,7 @@ func TestLog(t *testing.T) {
 	got := buf.String()
 	parts := strings.Split(got, " ")
 	wantP := "[WARN]"
-	wantS := "error [100.00 ms] [10 rows] query [arg]\n"
+	wantS := "error [100.00 ms] [10 rows] query [arg] [fp " + FingerprintHash("query") + "]\n"
 	if !strings.HasPrefix(got, wantP) {
 		t.Errorf("Prefix: want %s, got %s", wantP, parts[0])
 	}
@@ -433,8 +436,9 @@ func TestMakeLogMsg(t *testing.T) {
 		db := &DB{hideParams: tt.hideParams}
 		got := db.makeLogMsg(tt.cmd, tt.query, tt.args, tt.rows, tt.err, tt.elapsed)
 
-		if got != tt.want {
-			t.Errorf("#%d: want %s, got %s", i, tt.want, got)
+		want := tt.want + " [fp " + FingerprintHash(Fingerprint(tt.query)) + "]"
+		if got != want {
+			t.Errorf("#%d: want %s, got %s", i, want, got)
 		}
 		// t.Logf("#%d: want %s, got %s", i, tt.want, got)
 	}
@@ -448,6 +452,12 @@ func TestMySQL(t *testing.T) {
 	testGetMySQL(ctx, db, t)
 	testSelectMySQL(ctx, db, t)
 	testQueryMySQL(ctx, db, t)
+	testNamedGetMySQL(ctx, db, t)
+	testNamedSelectMySQL(ctx, db, t)
+	testNamedQueryMySQL(ctx, db, t)
+	testNamedLogMySQL(ctx, t)
+	testExpandInMySQL(ctx, t)
+	testQueryLogMySQL(ctx, t)
 	testRunInTxSuccessMySQL(ctx, db, t)
 	testRunInTxErrorMySQL(ctx, db, t)
 	testRunInTxRuntimePanicMySQL(ctx, db, t)
@@ -455,6 +465,13 @@ func TestMySQL(t *testing.T) {
 	testRunInTxNestMySQLCommit(ctx, db, t)
 	testRunInTxNestMySQLRollback(ctx, db, t)
 	testRunInTxNestMySQLPanic(ctx, db, t)
+	testRunInTxNestMySQLSavepoint(ctx, db, t)
+	testRunInTxNestMySQLSavepointPanic(ctx, db, t)
+	testRunInTxNestMySQLSavepointDeep(ctx, db, t)
+	testRunInTxWithRequiresNewMySQL(ctx, db, t)
+	testRunInTxWithReadOnlyMySQL(ctx, db, t)
+	testRunInTxWithCancelMySQL(ctx, db, t)
+	testRunInTxOnCommitMySQL(ctx, db, t)
 }
 
 func testExecMySQL(ctx context.Context, db *DB, t *testing.T) {
@@ -576,6 +593,270 @@ func testQueryMySQL(ctx context.Context, db *DB, t *testing.T) {
 	}
 }
 
+func testNamedGetMySQL(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	q := "SELECT id, email, password FROM user WHERE email = :email;"
+	var got User
+	err := db.NamedGet(ctx, &got, q, newUser("exec@example.com", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got.Email, "exec@example.com"; got != want {
+		t.Fatalf("wrong email: got %v, want %v", got, want)
+	}
+	err = db.NamedGet(ctx, &got, q, map[string]interface{}{"email": "123456789@example.com"})
+	if err != sql.ErrNoRows {
+		t.Fatalf("want sql.ErrNoRows, got %v", err)
+	}
+	err = db.NamedGet(ctx, &got, q, map[string]interface{}{})
+	if err == nil {
+		t.Fatalf("want non-nil error")
+	}
+}
+
+func testNamedSelectMySQL(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	q := `SELECT id, email, password FROM user WHERE email <> :email ORDER BY id;`
+	var got []User
+	err := db.NamedSelect(ctx, &got, q, map[string]interface{}{"email": "exec@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(got), 1; got != want {
+		t.Fatalf("wrong len: got %v, want %v", got, want)
+	}
+	if got, want := got[0].Email, "namedExec@example.com"; got != want {
+		t.Fatalf("wrong email: got %v, want %v", got, want)
+	}
+
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+		if _, err := db.Exec(ctx, "INSERT INTO user (email, password) VALUES (?, ?);", "namedSelect@example.com", testPassword); err != nil {
+			t.Fatal(err)
+		}
+		var got []User
+		if err := db.NamedSelect(ctx, &got, q, map[string]interface{}{"email": "exec@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(got), 2; got != want {
+			t.Fatalf("in tx: wrong len: got %v, want %v", got, want)
+		}
+		return errors.New("rollback")
+	})
+}
+
+func testNamedQueryMySQL(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	q := `SELECT id, email, password FROM user WHERE password = :password ORDER BY id;`
+	rows, err := db.NamedQuery(ctx, q, newUser("", testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var u User
+		if err := rows.StructScan(&u); err != nil {
+			t.Fatal(err)
+		}
+		emails = append(emails, u.Email)
+	}
+	if diff := cmp.Diff([]string{"exec@example.com", "namedExec@example.com"}, emails); diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+
+	rows, err = db.NamedQuery(ctx, q, map[string]interface{}{})
+	if err == nil || rows != nil {
+		t.Fatalf("want (nil, error), got (%v, %v)", rows, err)
+	}
+}
+
+func testNamedLogMySQL(ctx context.Context, t *testing.T) {
+	// t.Helper()
+
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil)
+	arg := map[string]interface{}{"email": "exec@example.com", "id": 0}
+
+	var u User
+	_ = db.NamedGet(ctx, &u, "SELECT id, email, password FROM user WHERE email = :email AND id > :id;", arg)
+	var us []User
+	_ = db.NamedSelect(ctx, &us, "SELECT id, email, password FROM user WHERE email = :email AND id > :id;", arg)
+	if rows, err := db.NamedQuery(ctx, "SELECT id, email, password FROM user WHERE email = :email AND id > :id;", arg); err == nil {
+		for rows.Next() {
+		}
+		_ = rows.Close()
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), 3; got != want {
+		t.Fatalf("wrong lines: got %v, want %v: %q", got, want, lines)
+	}
+	for i, cmd := range []string{CmdNamedGet, CmdNamedSelect, CmdNamedQuery} {
+		if !strings.Contains(lines[i], "["+cmd+"]") {
+			t.Errorf("#%d: want [%s], got %q", i, cmd, lines[i])
+		}
+		if want := "[1 rows] SELECT id, email, password FROM user WHERE email = :email AND id > :id; [exec@example.com, 0]"; !strings.Contains(lines[i], want) {
+			t.Errorf("#%d: want %q, got %q", i, want, lines[i])
+		}
+	}
+}
+
+func testExpandInMySQL(ctx context.Context, t *testing.T) {
+	// t.Helper()
+
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil).ExpandIn()
+	emails := []string{"exec@example.com", "namedExec@example.com"}
+
+	var got []User
+	if err := db.Select(ctx, &got, "SELECT id, email, password FROM user WHERE email IN (?) ORDER BY id;", emails); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(got), 2; got != want {
+		t.Fatalf("wrong len: got %v, want %v", got, want)
+	}
+	if want := "[2 rows] SELECT id, email, password FROM user WHERE email IN (?, ?) ORDER BY id; [exec@example.com, namedExec@example.com]"; !strings.Contains(buf.String(), want) {
+		t.Fatalf("want %q in the log, got %q", want, buf.String())
+	}
+
+	var n int
+	if err := db.Get(ctx, &n, "SELECT COUNT(*) FROM user WHERE id > ? AND email IN (?);", 0, emails[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1, got %d", n)
+	}
+
+	res, err := db.Exec(ctx, "UPDATE user SET password = password WHERE email IN (?);", emails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := res.RowsAffected(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query(ctx, "SELECT id FROM user WHERE email IN (?);", emails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = 0
+	for rows.Next() {
+		n++
+	}
+	_ = rows.Close()
+	if n != 2 {
+		t.Fatalf("want 2, got %d", n)
+	}
+
+	arg := struct {
+		Emails   []string `db:"emails"`
+		Password string   `db:"password"`
+	}{emails, testPassword}
+	buf.Reset()
+	got = nil
+	if err := db.NamedSelect(ctx, &got, "SELECT id, email, password FROM user WHERE email IN (:emails) AND password = :password;", arg); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(got), 2; got != want {
+		t.Fatalf("named: wrong len: got %v, want %v", got, want)
+	}
+	if want := "[N-SELECT] ["; !strings.Contains(buf.String(), want) {
+		t.Fatalf("want %q in the log, got %q", want, buf.String())
+	}
+	if want := "WHERE email IN (?, ?) AND password = ?; [exec@example.com, namedExec@example.com, " + testPassword + "]"; !strings.Contains(buf.String(), want) {
+		t.Fatalf("want %q in the log, got %q", want, buf.String())
+	}
+	if _, err := db.NamedExec(ctx, "UPDATE user SET password = :password WHERE email IN (:emails);", arg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Select(ctx, &got, "SELECT id FROM user WHERE email IN (?);", []string{}); err == nil {
+		t.Fatal("empty slice: want non-nil error")
+	}
+	if err := New(dbx, nil, nil).Select(ctx, &got, "SELECT id FROM user WHERE email IN (?);", emails); err == nil {
+		t.Fatal("without ExpandIn: want non-nil error")
+	}
+}
+
+func testQueryLogMySQL(ctx context.Context, t *testing.T) {
+	// t.Helper()
+
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil)
+	q := `SELECT id, email, password FROM user;`
+
+	// exhausted
+	rows, err := db.Query(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emails []string
+	for rows.Next() {
+		m := map[string]interface{}{}
+		if err := rows.MapScan(m); err != nil {
+			t.Fatal(err)
+		}
+		emails = append(emails, string(m["email"].([]byte)))
+	}
+	if got, want := len(emails), 2; got != want {
+		t.Fatalf("wrong len: got %v, want %v", got, want)
+	}
+	if got, want := strings.Count(buf.String(), "[QUERY]"), 1; got != want {
+		t.Fatalf("wrong log count: got %v, want %v", got, want)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(buf.String(), "[QUERY]"), 1; got != want {
+		t.Fatalf("wrong log count after Close: got %v, want %v", got, want)
+	}
+	if got, want := buf.String(), "[2 rows] "+q; !strings.Contains(got, want) {
+		t.Fatalf("wrong log: got %v, want %v", got, want)
+	}
+
+	// closed
+	buf.Reset()
+	rows, err = db.Query(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal("want true")
+	}
+	var row []interface{}
+	if row, err = rows.SliceScan(); err != nil {
+		t.Fatal(err)
+	} else if got, want := len(row), 3; got != want {
+		t.Fatalf("wrong columns: got %v, want %v", got, want)
+	}
+	if got := buf.String(); got != "" {
+		t.Fatalf("want no log before Close, got %v", got)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "[1 rows] "+q; !strings.Contains(got, want) {
+		t.Fatalf("wrong log: got %v, want %v", got, want)
+	}
+
+	// error
+	buf.Reset()
+	rows, err = db.Query(ctx, `SELECT no_such_column FROM user;`)
+	if err == nil {
+		t.Fatal("want non-nil error")
+	}
+	if rows != nil {
+		t.Fatal("want nil rows")
+	}
+	if got, want := buf.String(), "[WARN]"; !strings.HasPrefix(got, want) {
+		t.Fatalf("wrong log: got %v, want prefix %v", got, want)
+	}
+}
+
 func testRunInTxSuccessMySQL(ctx context.Context, db *DB, t *testing.T) {
 	// t.Helper()
 
@@ -792,7 +1073,7 @@ func testRunInTxNestMySQLRollback(ctx context.Context, db *DB, t *testing.T) {
 				return err
 			}
 			s, err = createSession(ctx, db, s) // duplicate error
-			return err                         // nested, so not rollback
+			return err                         // nested, so rollback to savepoint
 		})
 
 		return err // non-nil error, so rollback
@@ -865,6 +1146,459 @@ func testRunInTxNestMySQLPanic(ctx context.Context, db *DB, t *testing.T) {
 	}
 }
 
+func testRunInTxNestMySQLSavepoint(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	var (
+		email1 = "tx-nest-savepoint-1@example.com"
+		email2 = "tx-nest-savepoint-2@example.com"
+	)
+
+	s1 := newSession("tx-nest-savepoint", newUser(email1, testPassword))
+	s2 := newSession("tx-nest-savepoint", newUser(email2, testPassword))
+	var innerErr error
+	err, rbErr := db.RunInTx(ctx, func(ctx context.Context) error {
+		var err error
+		s1, err = createUser(ctx, db, s1) // success
+		if err != nil {
+			return err
+		}
+		s1, err = createSession(ctx, db, s1) // success
+		if err != nil {
+			return err
+		}
+
+		innerErr, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			var err error
+			s2, err = createUser(ctx, db, s2) // success
+			if err != nil {
+				return err
+			}
+			s2, err = createSession(ctx, db, s2) // duplicate error
+			return err                           // nested, so rollback to savepoint
+		})
+
+		return nil // outer continues, so commit
+	})
+
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if innerErr == nil {
+		t.Fatal("want non-nil error")
+	}
+
+	_, err = getUserByEmail(ctx, db, email1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = getSessionByID(ctx, db, s1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = getUserByEmail(ctx, db, email2)
+	if err != sql.ErrNoRows {
+		t.Fatal("want sql.ErrNoRows")
+	}
+}
+
+func testRunInTxNestMySQLSavepointPanic(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	var (
+		email1 = "tx-nest-savepoint-panic-1@example.com"
+		email2 = "tx-nest-savepoint-panic-2@example.com"
+		pnc    = "panic, plz rollback to savepoint"
+	)
+
+	var innerErr error
+	err, rbErr := db.RunInTx(ctx, func(ctx context.Context) error {
+		_, err := createUser(ctx, db, newSession("", newUser(email1, testPassword))) // success
+		if err != nil {
+			return err
+		}
+
+		innerErr, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			_, err := createUser(ctx, db, newSession("", newUser(email2, testPassword))) // success
+			if err != nil {
+				return err
+			}
+			panic(pnc) // nested, so rollback to savepoint
+		})
+
+		return nil // outer continues, so commit
+	})
+
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if innerErr == nil {
+		t.Fatal("want non-nil error")
+	} else if got, want := innerErr.Error(), pnc; !strings.Contains(got, want) {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+
+	_, err = getUserByEmail(ctx, db, email1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = getUserByEmail(ctx, db, email2)
+	if err != sql.ErrNoRows {
+		t.Fatal("want sql.ErrNoRows")
+	}
+}
+
+func testRunInTxNestMySQLSavepointDeep(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	var (
+		email1 = "tx-nest-savepoint-deep-1@example.com"
+		email2 = "tx-nest-savepoint-deep-2@example.com"
+		email3 = "tx-nest-savepoint-deep-3@example.com"
+	)
+
+	create := func(email string) TxFunc {
+		return func(ctx context.Context) error {
+			_, err := createUser(ctx, db, newSession("", newUser(email, testPassword)))
+			return err
+		}
+	}
+
+	err, rbErr := db.RunInTx(ctx, func(ctx context.Context) error {
+		if err := create(email1)(ctx); err != nil {
+			return err
+		}
+		err, _ := db.RunInTx(ctx, func(ctx context.Context) error {
+			if err := create(email2)(ctx); err != nil {
+				return err
+			}
+			_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+				if err := create(email3)(ctx); err != nil {
+					return err
+				}
+				return errors.New("rollback depth 2") // rollback to sqlxx_sp_2
+			})
+			return nil // release sqlxx_sp_1
+		})
+		return err
+	})
+
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{email1, email2} {
+		if _, err := getUserByEmail(ctx, db, email); err != nil {
+			t.Fatalf("%s: %v", email, err)
+		}
+	}
+
+	_, err = getUserByEmail(ctx, db, email3)
+	if err != sql.ErrNoRows {
+		t.Fatal("want sql.ErrNoRows")
+	}
+}
+
+func testRunInTxWithRequiresNewMySQL(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	var (
+		email1 = "tx-requires-new-1@example.com"
+		email2 = "tx-requires-new-2@example.com"
+	)
+
+	err, rbErr := db.RunInTx(ctx, func(ctx context.Context) error {
+		outer := db.txFromCtx(ctx)
+		_, err := createUser(ctx, db, newSession("", newUser(email1, testPassword))) // success
+		if err != nil {
+			return err
+		}
+
+		err, _ = db.RunInTxWith(ctx, &TxOption{Propagation: PropagationRequiresNew}, func(ctx context.Context) error {
+			if db.txFromCtx(ctx) == outer {
+				t.Error("want independent transaction")
+			}
+			_, err := createUser(ctx, db, newSession("", newUser(email2, testPassword))) // success
+			return err                                                                   // independent, so commit
+		})
+		if err != nil {
+			return err
+		}
+
+		return errors.New("rollback outer") // non-nil error, so rollback
+	})
+
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err == nil {
+		t.Fatal("want non-nil error")
+	}
+
+	_, err = getUserByEmail(ctx, db, email1)
+	if err != sql.ErrNoRows {
+		t.Fatal("want sql.ErrNoRows")
+	}
+
+	_, err = getUserByEmail(ctx, db, email2)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRunInTxWithReadOnlyMySQL(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	email1 := "tx-read-only-1@example.com"
+	yes, no := true, false
+	readOnly := &TxOption{ReadOnly: &yes}
+
+	var nestErr, nilErr, mandatoryErr error
+	err, rbErr := db.RunInTxWith(ctx, readOnly, func(ctx context.Context) error {
+		nestErr, _ = db.RunInTxWith(ctx, &TxOption{ReadOnly: &no}, func(ctx context.Context) error {
+			t.Error("must not be called")
+			return nil
+		})
+		nilErr, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			return nil // inherits read-only
+		})
+		mandatoryErr, _ = db.RunInTxWith(ctx, &TxOption{Propagation: PropagationMandatory}, func(ctx context.Context) error {
+			return nil // ReadOnly unset, so joins the read-only transaction
+		})
+		_, err := createUser(ctx, db, newSession("", newUser(email1, testPassword))) // read-only error
+		return err
+	})
+
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err == nil {
+		t.Fatal("want non-nil error")
+	}
+	if !xerrors.Is(nestErr, ErrIncompatibleTx) {
+		t.Fatalf("want ErrIncompatibleTx, got %v", nestErr)
+	}
+	if nilErr != nil {
+		t.Fatal(nilErr)
+	}
+	if mandatoryErr != nil {
+		t.Fatal(mandatoryErr)
+	}
+
+	_, err = getUserByEmail(ctx, db, email1)
+	if err != sql.ErrNoRows {
+		t.Fatal("want sql.ErrNoRows")
+	}
+}
+
+func testRunInTxWithCancelMySQL(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	email1 := "tx-cancel-1@example.com"
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err, rbErr := db.RunInTx(canceled, func(ctx context.Context) error {
+		t.Error("must not be called")
+		return nil
+	})
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err != context.Canceled {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	err, rbErr = db.RunInTx(cancelCtx, func(ctx context.Context) error {
+		_, err := createUser(ctx, db, newSession("", newUser(email1, testPassword))) // success
+		cancel()                                                                     // rollback
+		return err
+	})
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err != context.Canceled {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+
+	_, err = getUserByEmail(ctx, db, email1)
+	if err != sql.ErrNoRows {
+		t.Fatal("want sql.ErrNoRows")
+	}
+}
+
+func testRunInTxOnCommitMySQL(ctx context.Context, db *DB, t *testing.T) {
+	// t.Helper()
+
+	email1 := "tx-on-commit-1@example.com"
+
+	var found bool
+	err, rbErr := db.RunInTx(ctx, func(ctx context.Context) error {
+		_, err := createUser(ctx, db, newSession("", newUser(email1, testPassword))) // success
+		if err != nil {
+			return err
+		}
+		return OnCommit(ctx, func(ctx context.Context) {
+			_, err := getUserByEmail(ctx, db, email1) // committed, so visible outside the transaction
+			found = err == nil
+		})
+	})
+
+	if rbErr != nil {
+		t.Fatal(rbErr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("want committed user")
+	}
+}
+
+func TestCheckTxOptions(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		cur     sql.TxOptions
+		opts    *TxOption
+		wantErr bool
+	}{
+		{sql.TxOptions{}, nil, false},
+		{sql.TxOptions{ReadOnly: true}, nil, false},
+		{sql.TxOptions{}, &TxOption{}, false},
+		{sql.TxOptions{}, &TxOption{ReadOnly: &yes}, false},
+		{sql.TxOptions{}, &TxOption{ReadOnly: &no}, false},
+		{sql.TxOptions{ReadOnly: true}, &TxOption{}, false},
+		{sql.TxOptions{ReadOnly: true}, &TxOption{Propagation: PropagationMandatory}, false},
+		{sql.TxOptions{ReadOnly: true}, &TxOption{ReadOnly: &yes}, false},
+		{sql.TxOptions{ReadOnly: true}, &TxOption{ReadOnly: &no}, true},
+		{sql.TxOptions{Isolation: sql.LevelSerializable}, &TxOption{}, false},
+		{sql.TxOptions{Isolation: sql.LevelSerializable}, &TxOption{Isolation: sql.LevelSerializable}, false},
+		{sql.TxOptions{Isolation: sql.LevelSerializable}, &TxOption{Isolation: sql.LevelReadCommitted}, true},
+		{sql.TxOptions{}, &TxOption{Isolation: sql.LevelReadCommitted}, true},
+	}
+
+	for i, tt := range tests {
+		err := checkTxOptions(tt.cur, tt.opts)
+		if got := err != nil; got != tt.wantErr {
+			t.Errorf("%d: want error %t, got %v", i, tt.wantErr, err)
+		}
+		if err != nil && !xerrors.Is(err, ErrIncompatibleTx) {
+			t.Errorf("%d: want ErrIncompatibleTx, got %v", i, err)
+		}
+	}
+}
+
+func TestRunInTxWith(t *testing.T) {
+	tx, err := dbx.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	ctx := context.Background()
+	txCtx := db.newTxCtx(ctx, tx)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		prop     Propagation
+		wantErr  error
+		wantInTx bool
+		wantCall bool
+	}{
+		{"mandatory/no-tx", ctx, PropagationMandatory, ErrTxRequired, false, false},
+		{"mandatory/tx", txCtx, PropagationMandatory, nil, true, true},
+		{"never/no-tx", ctx, PropagationNever, nil, false, true},
+		{"never/tx", txCtx, PropagationNever, ErrTxExists, false, false},
+		{"supports/no-tx", ctx, PropagationSupports, nil, false, true},
+		{"supports/tx", txCtx, PropagationSupports, nil, true, true},
+		{"not-supported/no-tx", ctx, PropagationNotSupported, nil, false, true},
+		{"not-supported/tx", txCtx, PropagationNotSupported, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called, inTx bool
+			err, rbErr := db.RunInTxWith(tt.ctx, &TxOption{Propagation: tt.prop}, func(ctx context.Context) error {
+				called = true
+				inTx = db.IsInTx(ctx)
+				if _, ok := db.build(ctx).(*sqlx.Tx); ok != inTx {
+					t.Errorf("build: want *sqlx.Tx %t, got %T", inTx, db.build(ctx))
+				}
+				return nil
+			})
+
+			if rbErr != nil {
+				t.Fatal(rbErr)
+			}
+			if err != tt.wantErr {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if called != tt.wantCall {
+				t.Errorf("called: want %t, got %t", tt.wantCall, called)
+			}
+			if inTx != tt.wantInTx {
+				t.Errorf("in tx: want %t, got %t", tt.wantInTx, inTx)
+			}
+		})
+	}
+
+	err, _ = db.RunInTxWith(ctx, &TxOption{Propagation: Propagation(-1)}, func(ctx context.Context) error {
+		t.Error("must not be called")
+		return nil
+	})
+	if err == nil {
+		t.Error("want non-nil error")
+	}
+}
+
+func TestSavepointName(t *testing.T) {
+	var got []string
+	record := func(ctx context.Context) {
+		got = append(got, savepointName(db.txState(ctx).depth))
+	}
+
+	_, _ = db.RunInTx(context.Background(), func(ctx context.Context) error {
+		_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			record(ctx)
+			_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+				record(ctx)
+				_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+					record(ctx)
+					return nil
+				})
+				return nil
+			})
+			return nil
+		})
+		_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			record(ctx)
+			return nil
+		})
+		return nil
+	})
+
+	if want := []string{"sqlxx_sp_1", "sqlxx_sp_2", "sqlxx_sp_3", "sqlxx_sp_1"}; !cmp.Equal(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
 func TestIsInTx(t *testing.T) {
 	tx, err := dbx.Beginx()
 	if err != nil {
@@ -881,23 +1615,222 @@ func TestIsInTx(t *testing.T) {
 		want bool
 	}{
 		{ctx, false},
-		{newTxCtx(ctx, nil), false},
-		{newTxCtx(ctx, (*sqlx.Tx)(nil)), false},
-		{newTxCtx(ctx, tx), true},
+		{db.newTxCtx(ctx, nil), false},
+		{db.newTxCtx(ctx, (*sqlx.Tx)(nil)), false},
+		{db.newTxCtx(ctx, tx), true},
+		{db.newTxCtx(db.newTxCtx(ctx, tx), nil), false},
 	}
 
 	for i, tt := range tests {
-		got := IsInTx(tt.ctx)
-
-		if got != tt.want {
+		if got := db.IsInTx(tt.ctx); got != tt.want {
+			t.Errorf("%d: want %t, got %t", i, tt.want, got)
+		}
+		if got := IsInTx(tt.ctx); got != tt.want {
 			t.Errorf("%d: want %t, got %t", i, tt.want, got)
 		}
 	}
 }
 
+func TestIsInTxMultipleDB(t *testing.T) {
+	dbx2, err := sqlx.Connect("mysql", testDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbx2.Close()
+
+	db1 := New(dbx, nil, nil)
+	db2 := New(dbx2, nil, nil)
+	ctx := context.Background()
+
+	_, _ = db1.RunInTx(ctx, func(ctx context.Context) error {
+		tx1 := db1.txFromCtx(ctx)
+
+		if !db1.IsInTx(ctx) {
+			t.Error("db1: want in tx")
+		}
+		if !db1.Secret().IsInTx(ctx) {
+			t.Error("db1.Secret(): want in tx")
+		}
+		if db2.IsInTx(ctx) {
+			t.Error("db2: want not in tx")
+		}
+		if _, ok := db2.build(ctx).(*sqlx.DB); !ok {
+			t.Errorf("db2: want *sqlx.DB, got %T", db2.build(ctx))
+		}
+
+		_, _ = db2.RunInTx(ctx, func(ctx context.Context) error {
+			tx2 := db2.txFromCtx(ctx)
+			if tx2 == nil || tx2 == tx1 {
+				t.Error("db2: want its own transaction")
+			}
+			if db2.txState(ctx).depth != 0 {
+				t.Error("db2: want new transaction, not savepoint")
+			}
+			if got := db1.txFromCtx(ctx); got != tx1 {
+				t.Error("db1: want the same transaction")
+			}
+			if !IsInTx(ctx) {
+				t.Error("want in tx")
+			}
+			return nil
+		})
+
+		return nil
+	})
+}
+
 // func isMysqlErrDupEntry(err error) bool {
 // 	if driverErr, ok := err.(*mysql.MySQLError); ok {
 // 		return driverErr.Number == mysqlerr.ER_DUP_ENTRY // 1062
 // 	}
 // 	return false
 // }
+
+func TestExpandIn(t *testing.T) {
+	pg := New(sqlx.NewDb(dbx.DB, "postgres"), nil, nil).ExpandIn()
+
+	tests := []struct {
+		db        *DB
+		info      QueryInfo
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			db.ExpandIn(),
+			QueryInfo{Command: CmdSelect, Query: "SELECT * FROM user WHERE id IN (?) AND email = ?", Args: []interface{}{[]int{1, 2, 3}, "a"}},
+			"SELECT * FROM user WHERE id IN (?, ?, ?) AND email = ?",
+			[]interface{}{1, 2, 3, "a"},
+		},
+		{
+			pg,
+			QueryInfo{Command: CmdExec, Query: "DELETE FROM user WHERE id IN (?) AND email = ?", Args: []interface{}{[]int64{1, 2}, "a"}},
+			"DELETE FROM user WHERE id IN ($1, $2) AND email = $3",
+			[]interface{}{int64(1), int64(2), "a"},
+		},
+		{
+			pg,
+			QueryInfo{Command: CmdNamedGet, Query: "SELECT * FROM user WHERE id IN (:ids)", named: true, arg: map[string]interface{}{"ids": []int{1, 2}}},
+			"SELECT * FROM user WHERE id IN ($1, $2)",
+			[]interface{}{1, 2},
+		},
+		{
+			db.ExpandIn(),
+			QueryInfo{Command: CmdExec, Query: "UPDATE user SET email = ? WHERE id = ?", Args: []interface{}{nil, 1}},
+			"UPDATE user SET email = ? WHERE id = ?",
+			[]interface{}{nil, 1},
+		},
+		{
+			db.ExpandIn(),
+			QueryInfo{Command: CmdExec, Query: "UPDATE user SET email = ? WHERE id IN (?) AND password = ?", Args: []interface{}{sql.NullString{}, []int{1, 2}, nil}},
+			"UPDATE user SET email = ? WHERE id IN (?, ?) AND password = ?",
+			[]interface{}{sql.NullString{}, 1, 2, nil},
+		},
+		{
+			db.ExpandIn(),
+			QueryInfo{Command: CmdSelect, Query: "SELECT * FROM user WHERE password = ?", Args: []interface{}{[]byte("p")}},
+			"SELECT * FROM user WHERE password = ?",
+			[]interface{}{[]byte("p")},
+		},
+		{
+			db,
+			QueryInfo{Command: CmdSelect, Query: "SELECT * FROM user WHERE id IN (?)", Args: []interface{}{[]int{1, 2}}},
+			"SELECT * FROM user WHERE id IN (?)",
+			[]interface{}{[]int{1, 2}},
+		},
+	}
+
+	for i, tt := range tests {
+		info := tt.info
+		err := tt.db.inInterceptor(context.Background(), &info, func(ctx context.Context, info *QueryInfo) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if info.Query != tt.wantQuery {
+			t.Errorf("#%d: want %q, got %q", i, tt.wantQuery, info.Query)
+		}
+		if diff := cmp.Diff(tt.wantArgs, info.Args); diff != "" {
+			t.Errorf("#%d: (-want +got):\n%s", i, diff)
+		}
+		if info.bound != info.named {
+			t.Errorf("#%d: want bound %t, got %t", i, info.named, info.bound)
+		}
+	}
+
+	for _, tt := range []QueryInfo{
+		{Command: CmdSelect, Query: "SELECT * FROM user WHERE id IN (?)", Args: []interface{}{[]int{}}},
+		{Command: CmdSelect, Query: "SELECT * FROM user WHERE id IN (?)", Args: []interface{}{[]int{1}, 2}},
+		{Command: CmdSelect, Query: "SELECT * FROM user WHERE id IN (?) AND email = ?", Args: []interface{}{[]int{1}}},
+	} {
+		info := tt
+		err := db.ExpandIn().inInterceptor(context.Background(), &info, func(ctx context.Context, info *QueryInfo) error {
+			return nil
+		})
+		if err == nil {
+			t.Errorf("%s %v: want non-nil error", tt.Query, tt.Args)
+		}
+	}
+}
+
+func TestEach(t *testing.T) {
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil)
+	errRollback := errors.New("rollback")
+	errStop := errors.New("stop")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		for _, email := range []string{"each1@example.com", "each2@example.com", "each3@example.com"} {
+			if _, err := db.Exec(ctx, "INSERT INTO user (email, password) VALUES (?, ?)", email, testPassword); err != nil {
+				t.Fatal(err)
+			}
+		}
+		buf.Reset()
+
+		const query = "SELECT id, email, password FROM user WHERE email LIKE ? ORDER BY email"
+		var got []string
+		err := db.Each(ctx, query, []interface{}{"each%"}, func(rows *Rows) error {
+			var u User
+			if err := rows.StructScan(&u); err != nil {
+				return err
+			}
+			got = append(got, u.Email)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"each1@example.com", "each2@example.com", "each3@example.com"}, got); diff != "" {
+			t.Errorf("(-want +got):\n%s", diff)
+		}
+		if n := strings.Count(buf.String(), "[EACH]"); n != 1 || !strings.Contains(buf.String(), "[3 rows]") {
+			t.Errorf("want logged once with 3 rows, got %q", buf.String())
+		}
+
+		buf.Reset()
+		n := 0
+		err = db.Each(ctx, query, []interface{}{"each%"}, func(rows *Rows) error {
+			if n++; n == 2 {
+				return errStop
+			}
+			return nil
+		})
+		if err != errStop {
+			t.Errorf("want errStop, got %v", err)
+		}
+		if n != 2 {
+			t.Errorf("want stopped at 2, got %d", n)
+		}
+		if cnt := strings.Count(buf.String(), "[EACH]"); cnt != 1 || !strings.Contains(buf.String(), "[2 rows]") {
+			t.Errorf("want logged once with 2 rows, got %q", buf.String())
+		}
+		if !strings.Contains(buf.String(), "[WARN]") || !strings.Contains(buf.String(), errStop.Error()) {
+			t.Errorf("want logged with errStop, got %q", buf.String())
+		}
+
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+}
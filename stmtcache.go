@@ -0,0 +1,209 @@
+package sqlxx
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	StmtCacheHit  = "hit"
+	StmtCacheMiss = "miss"
+)
+
+// stmtCache is an LRU cache of prepared statements keyed by the database
+// and the query text. Evicted statements are closed once no query uses them.
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[stmtKey]*list.Element
+}
+
+type stmtKey struct {
+	dbx   *sqlx.DB
+	query string
+}
+
+type stmtEntry struct {
+	key     stmtKey
+	stmt    *sqlx.Stmt
+	refs    int
+	evicted bool
+}
+
+// WithStmtCache returns a copy of db which prepares every query and keeps up
+// to size statements, evicting the least recently used ones. The cache is
+// shared by the primary and the replicas, so size bounds the statements
+// prepared on all of them together. In a transaction the cached statement is bound to the transaction with
+// tx.Stmtx. Whether the statement was cached is logged as [stmt hit] or
+// [stmt miss]. A size of 0 or less disables the cache. Call Close to close
+// the cached statements.
+func (db *DB) WithStmtCache(size int) *DB {
+	clone := db.clone()
+	clone.stmts = nil
+	if size > 0 {
+		clone.stmts = &stmtCache{size: size, ll: list.New(), items: map[stmtKey]*list.Element{}}
+	}
+	return clone
+}
+
+// acquire returns the statement of query prepared on dbx and reports whether
+// it was cached. release must be called when the statement is no longer used.
+func (c *stmtCache) acquire(ctx context.Context, dbx *sqlx.DB, query string) (stmt *sqlx.Stmt, release func(), hit bool, err error) {
+	key := stmtKey{dbx, query}
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		e := el.Value.(*stmtEntry)
+		e.refs++
+		c.mu.Unlock()
+		return e.stmt, c.releaser(e), true, nil
+	}
+	c.mu.Unlock()
+
+	stmt, err = dbx.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok { // prepared concurrently
+		_ = stmt.Close()
+		c.ll.MoveToFront(el)
+		e := el.Value.(*stmtEntry)
+		e.refs++
+		return e.stmt, c.releaser(e), false, nil
+	}
+
+	e := &stmtEntry{key: key, stmt: stmt, refs: 1}
+	c.items[key] = c.ll.PushFront(e)
+	for c.ll.Len() > c.size {
+		c.evict(c.ll.Back())
+	}
+	return stmt, c.releaser(e), false, nil
+}
+
+func (c *stmtCache) releaser(e *stmtEntry) func() {
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		e.refs--
+		if e.evicted && e.refs == 0 {
+			_ = e.stmt.Close()
+		}
+	}
+}
+
+// evict must be called with c.mu held.
+func (c *stmtCache) evict(el *list.Element) {
+	e := c.ll.Remove(el).(*stmtEntry)
+	delete(c.items, e.key)
+	e.evicted = true
+	if e.refs == 0 {
+		_ = e.stmt.Close()
+	}
+}
+
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.evict(c.ll.Back())
+	}
+}
+
+func (c *stmtCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// cached returns q itself if the statement cache is disabled, or a queryer
+// which runs the queries with the cached statements and records whether the
+// statement was cached to info.
+func (db *DB) cached(q queryer, info *QueryInfo) queryer {
+	if db.stmts == nil {
+		return q
+	}
+	return &stmtQueryer{db: db, q: q, info: info}
+}
+
+type stmtQueryer struct {
+	db   *DB
+	q    queryer
+	info *QueryInfo
+}
+
+// stmt returns the statement of query for s.q, which is a *sqlx.DB or a
+// *sqlx.Tx of the primary. Unless keep is set, the transaction-scoped
+// statement is closed by release; otherwise it is closed with the
+// transaction, because Rows may still use it.
+func (s *stmtQueryer) stmt(ctx context.Context, query string, keep bool) (*sqlx.Stmt, func(), error) {
+	dbx, _ := s.q.(*sqlx.DB)
+	tx, _ := s.q.(*sqlx.Tx)
+	if tx != nil {
+		dbx = s.db.dbx
+	}
+
+	stmt, release, hit, err := s.db.stmts.acquire(ctx, dbx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.info.StmtCache = StmtCacheMiss
+	if hit {
+		s.info.StmtCache = StmtCacheHit
+	}
+	if tx == nil {
+		return stmt, release, nil
+	}
+
+	txStmt := tx.StmtxContext(ctx, stmt)
+	return txStmt, func() {
+		if !keep {
+			_ = txStmt.Close()
+		}
+		release()
+	}, nil
+}
+
+func (s *stmtQueryer) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	stmt, release, err := s.stmt(ctx, query, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.QueryxContext(ctx, args...)
+}
+
+func (s *stmtQueryer) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	stmt, release, err := s.stmt(ctx, query, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return stmt.GetContext(ctx, dest, args...)
+}
+
+func (s *stmtQueryer) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	stmt, release, err := s.stmt(ctx, query, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return stmt.SelectContext(ctx, dest, args...)
+}
+
+func (s *stmtQueryer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, release, err := s.stmt(ctx, query, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.ExecContext(ctx, args...)
+}
@@ -0,0 +1,152 @@
+package sqlxx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStmtCache(t *testing.T) {
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil).WithStmtCache(2)
+	defer db.Close()
+	ctx := context.Background()
+
+	queries := []string{
+		"SELECT COUNT(*) FROM user WHERE id > ?;",
+		"SELECT COUNT(*) FROM user WHERE id > ?;",
+		"SELECT COUNT(*) FROM user WHERE id >= ?;",
+		"SELECT COUNT(*) FROM user WHERE id <> ?;",
+		"SELECT COUNT(*) FROM user WHERE id > ?;",
+	}
+	for _, q := range queries {
+		var n int
+		if err := db.Get(ctx, &n, q, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{"[stmt miss]", "[stmt hit]", "[stmt miss]", "[stmt miss]", "[stmt miss]"}
+	if len(lines) != len(want) {
+		t.Fatalf("want %d lines, got %q", len(want), lines)
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(lines[i], w) {
+			t.Errorf("#%d: want suffix %s, got %q", i, w, lines[i])
+		}
+	}
+	if got, want := db.stmts.len(), 2; got != want {
+		t.Errorf("wrong len: got %v, want %v", got, want)
+	}
+}
+
+func TestStmtCacheEvict(t *testing.T) {
+	c := New(dbx, nil, nil).WithStmtCache(1).stmts
+	ctx := context.Background()
+
+	stmt, release, _, err := c.acquire(ctx, dbx, "SELECT COUNT(*) FROM user;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, release2, _, err := c.acquire(ctx, dbx, "SELECT COUNT(*) FROM session;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release2()
+
+	var n int
+	if err := stmt.Get(&n); err != nil {
+		t.Fatalf("must not be closed while in use: %v", err)
+	}
+	release()
+	if err := stmt.Get(&n); err == nil {
+		t.Fatal("want closed after release")
+	}
+}
+
+func TestStmtCacheClose(t *testing.T) {
+	db := New(dbx, nil, nil).WithStmtCache(10)
+	ctx := context.Background()
+
+	stmt, release, _, err := db.stmts.acquire(ctx, dbx, "SELECT COUNT(*) FROM user;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	_ = db.Close()
+
+	if got := db.stmts.len(); got != 0 {
+		t.Errorf("want empty, got %v", got)
+	}
+	var n int
+	if err := stmt.Get(&n); err == nil {
+		t.Fatal("want closed")
+	}
+}
+
+func TestStmtCacheTx(t *testing.T) {
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil).WithStmtCache(10)
+	defer db.Close()
+	errRollback := errors.New("rollback")
+	q := "SELECT COUNT(*) FROM user WHERE email = ?;"
+
+	var n int
+	if err := db.Get(context.Background(), &n, q, "stmtcache@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		if _, err := db.Exec(ctx, "INSERT INTO user (email, password) VALUES (?, ?);", "stmtcache@example.com", testPassword); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Get(ctx, &n, q, "stmtcache@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("want the row inserted in the tx, got %d", n)
+		}
+
+		rows, err := db.Query(ctx, "SELECT email FROM user WHERE email = ?;", "stmtcache@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var emails []string
+		for rows.Next() {
+			var email string
+			if err := rows.Scan(&email); err != nil {
+				t.Fatal(err)
+			}
+			emails = append(emails, email)
+		}
+		_ = rows.Close()
+		if len(emails) != 1 {
+			t.Errorf("want 1 row, got %v", emails)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("want 4 lines, got %q", lines)
+	}
+	if !strings.HasSuffix(lines[2], "[stmt hit]") {
+		t.Errorf("want the cached statement in the tx, got %q", lines[2])
+	}
+}
+
+func TestStmtCacheDisabled(t *testing.T) {
+	db := New(dbx, nil, nil).WithStmtCache(10).WithStmtCache(0)
+	if db.stmts != nil {
+		t.Error("want disabled")
+	}
+	if q := db.cached(dbx, &QueryInfo{}); q != dbx {
+		t.Errorf("want *sqlx.DB as is, got %T", q)
+	}
+}
@@ -0,0 +1,173 @@
+// Package otelinterceptor provides a sqlxx.Interceptor which creates
+// OpenTelemetry spans for queries and transactions.
+package otelinterceptor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/rema424/sqlxx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/rema424/sqlxx"
+
+const (
+	attrCommand      = attribute.Key("sqlxx.command")
+	attrArgs         = attribute.Key("sqlxx.args")
+	attrRowsAffected = attribute.Key("db.rows_affected")
+	attrRowsReturned = attribute.Key("db.rows_returned")
+	attrTxOutcome    = attribute.Key("sqlxx.tx.outcome")
+	attrTxIsolation  = attribute.Key("sqlxx.tx.isolation")
+	attrTxReadOnly   = attribute.Key("sqlxx.tx.read_only")
+)
+
+type tracer struct {
+	t      trace.Tracer
+	system attribute.KeyValue
+}
+
+// NewInterceptor returns an interceptor which creates a span for every query
+// and transaction. driverName is the driver of the *sqlx.DB, e.g. "mysql",
+// and sets the db.system attribute. Parameters are attached to the spans
+// unless they are hidden by Secret or Option.HideParams.
+func NewInterceptor(tp trace.TracerProvider, driverName string) sqlxx.Interceptor {
+	tr := &tracer{t: tp.Tracer(tracerName), system: dbSystem(driverName)}
+	return tr.intercept
+}
+
+func (tr *tracer) intercept(ctx context.Context, info *sqlxx.QueryInfo, next sqlxx.Handler) error {
+	switch info.Command {
+	case sqlxx.CmdBegin, sqlxx.CmdCommit, sqlxx.CmdRollback:
+		return next(ctx, info)
+	case sqlxx.CmdTx, sqlxx.CmdSavepoint:
+		ctx, span := tr.startTxSpan(ctx, info)
+		err := next(ctx, info)
+		endTxSpan(span, err)
+		return err
+	}
+
+	ctx, span := tr.startSpan(ctx, info)
+	err := next(ctx, info)
+	info.OnFinish(func(err error) {
+		endSpan(span, info.Command, err, info.Rows)
+	})
+	return err
+}
+
+func (tr *tracer) startSpan(ctx context.Context, info *sqlxx.QueryInfo) (context.Context, trace.Span) {
+	op := queryOperation(info.Query)
+	name := op
+	if name == "" {
+		name = info.Command
+	}
+
+	attrs := []attribute.KeyValue{
+		tr.system,
+		semconv.DBStatementKey.String(info.Query),
+		attrCommand.String(info.Command),
+	}
+	if op != "" {
+		attrs = append(attrs, semconv.DBOperationKey.String(op))
+	}
+	if !info.HideParams {
+		attrs = append(attrs, attrArgs.StringSlice(formatArgs(info.Args)))
+	}
+
+	return tr.t.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, cmd string, err error, rows int) {
+	if !span.IsRecording() {
+		return
+	}
+
+	switch cmd {
+	case sqlxx.CmdExec, sqlxx.CmdNamedExec:
+		span.SetAttributes(attrRowsAffected.Int(rows))
+	default:
+		span.SetAttributes(attrRowsReturned.Int(rows))
+	}
+	setSpanError(span, err)
+	span.End()
+}
+
+func (tr *tracer) startTxSpan(ctx context.Context, info *sqlxx.QueryInfo) (context.Context, trace.Span) {
+	name := "sqlxx.tx"
+	if info.Command == sqlxx.CmdSavepoint {
+		name = "sqlxx.savepoint"
+	}
+
+	attrs := []attribute.KeyValue{tr.system}
+	if opts := info.TxOptions; opts != nil {
+		attrs = append(attrs,
+			attrTxIsolation.String(opts.Isolation.String()),
+			attrTxReadOnly.Bool(opts.ReadOnly),
+		)
+	}
+
+	return tr.t.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endTxSpan(span trace.Span, err error) {
+	if !span.IsRecording() {
+		return
+	}
+
+	if err != nil {
+		span.SetAttributes(attrTxOutcome.String("rollback"))
+	} else {
+		span.SetAttributes(attrTxOutcome.String("commit"))
+	}
+	setSpanError(span, err)
+	span.End()
+}
+
+func setSpanError(span trace.Span, err error) {
+	if err == nil || err == sql.ErrNoRows {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func dbSystem(driverName string) attribute.KeyValue {
+	switch driverName {
+	case "mysql":
+		return semconv.DBSystemMySQL
+	case "postgres", "pgx", "pq-timeouts", "cloudsqlpostgres":
+		return semconv.DBSystemPostgreSQL
+	case "sqlite3", "sqlite":
+		return semconv.DBSystemSqlite
+	case "sqlserver", "mssql":
+		return semconv.DBSystemMSSQL
+	case "oracle", "ora", "goracle", "godror":
+		return semconv.DBSystemOracle
+	}
+	return semconv.DBSystemOtherSQL
+}
+
+// queryOperation returns the first keyword of query in upper case, e.g. SELECT.
+func queryOperation(query string) string {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+	})
+	if end < 0 {
+		end = len(query)
+	}
+	return strings.ToUpper(query[:end])
+}
+
+func formatArgs(args []interface{}) []string {
+	ss := make([]string, len(args))
+	for i, arg := range args {
+		ss[i] = fmt.Sprint(arg)
+	}
+	return ss
+}
@@ -0,0 +1,280 @@
+package otelinterceptor
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/rema424/sqlxx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testDSN = "sqlxxtester:Passw0rd!@tcp(127.0.0.1:3306)/sqlxxtest?collation=utf8mb4_bin&interpolateParams=true&parseTime=true&maxAllowedPacket=0"
+
+const (
+	dropItem   = `DROP TABLE IF EXISTS otel_item;`
+	createItem = `CREATE TABLE otel_item (id INT AUTO_INCREMENT PRIMARY KEY, name VARCHAR(255) NOT NULL);`
+)
+
+var dbx *sqlx.DB
+
+func TestMain(m *testing.M) {
+	var err error
+	dbx, err = sqlx.Connect("mysql", testDSN)
+	if err != nil {
+		log.Fatalf("sqlx.Connect: %v", err)
+	}
+	defer dbx.Close()
+
+	dbx.MustExec(dropItem)
+	dbx.MustExec(createItem)
+
+	os.Exit(m.Run())
+}
+
+func newTracingDB(t *testing.T) (*sqlxx.DB, *tracetest.InMemoryExporter, trace.Tracer) {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	db := sqlxx.New(dbx, nil, nil).WithInterceptors(NewInterceptor(tp, dbx.DriverName()))
+	return db, exp, tp.Tracer("test")
+}
+
+func spanAttrs(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestQuery(t *testing.T) {
+	db, exp, tracer := newTracingDB(t)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	var n int
+	err := db.Get(ctx, &n, "SELECT COUNT(*) FROM otel_item WHERE name = ?;", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := exp.GetSpans()
+	if got, want := len(spans), 2; got != want {
+		t.Fatalf("wrong spans: got %v, want %v", got, want)
+	}
+
+	s := spans[0]
+	if got, want := s.Name, "SELECT"; got != want {
+		t.Errorf("wrong name: got %v, want %v", got, want)
+	}
+	if got, want := s.SpanKind, trace.SpanKindClient; got != want {
+		t.Errorf("wrong kind: got %v, want %v", got, want)
+	}
+	if got, want := s.Parent.SpanID(), parent.SpanContext().SpanID(); got != want {
+		t.Errorf("wrong parent: got %v, want %v", got, want)
+	}
+
+	attrs := spanAttrs(s)
+	tests := []struct {
+		key  attribute.Key
+		want string
+	}{
+		{"db.system", "mysql"},
+		{"db.statement", "SELECT COUNT(*) FROM otel_item WHERE name = ?;"},
+		{"db.operation", "SELECT"},
+		{"sqlxx.command", sqlxx.CmdGet},
+		{"sqlxx.args", `[tracing]`},
+		{"db.rows_returned", "1"},
+	}
+	for _, tt := range tests {
+		if got := attrs[tt.key].Emit(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSecret(t *testing.T) {
+	db, exp, _ := newTracingDB(t)
+
+	var n int
+	if err := db.Secret().Get(context.Background(), &n, "SELECT COUNT(*) FROM otel_item WHERE name = ?;", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exp.GetSpans()
+	if got, want := len(spans), 1; got != want {
+		t.Fatalf("wrong spans: got %v, want %v", got, want)
+	}
+	if v, ok := spanAttrs(spans[0])["sqlxx.args"]; ok {
+		t.Errorf("want no args, got %v", v.Emit())
+	}
+}
+
+func TestError(t *testing.T) {
+	db, exp, _ := newTracingDB(t)
+	ctx := context.Background()
+
+	_, err := db.Exec(ctx, "SELECT no_such_column FROM otel_item;")
+	if err == nil {
+		t.Fatal("want non-nil error")
+	}
+	var name string
+	if err := db.Get(ctx, &name, "SELECT name FROM otel_item WHERE id = ?;", -1); err == nil {
+		t.Fatal("want non-nil error")
+	}
+
+	spans := exp.GetSpans()
+	if got, want := len(spans), 2; got != want {
+		t.Fatalf("wrong spans: got %v, want %v", got, want)
+	}
+	if got, want := spans[0].Status.Code, codes.Error; got != want {
+		t.Errorf("wrong status: got %v, want %v", got, want)
+	}
+	if got, want := len(spans[0].Events), 1; got != want {
+		t.Errorf("wrong events: got %v, want %v", got, want)
+	}
+	if got, want := spans[1].Status.Code, codes.Unset; got != want {
+		t.Errorf("sql.ErrNoRows: wrong status: got %v, want %v", got, want)
+	}
+}
+
+func TestRows(t *testing.T) {
+	db, exp, _ := newTracingDB(t)
+
+	rows, err := db.Query(context.Background(), "SELECT 1 UNION ALL SELECT 2;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for rows.Next() {
+		n++
+	}
+	if got := len(exp.GetSpans()); got != 1 {
+		t.Fatalf("want span ended on exhaustion, got %d spans", got)
+	}
+	_ = rows.Close()
+
+	spans := exp.GetSpans()
+	if got, want := len(spans), 1; got != want {
+		t.Fatalf("wrong spans: got %v, want %v", got, want)
+	}
+	if got, want := spanAttrs(spans[0])["db.rows_returned"].AsInt64(), int64(n); got != want || n != 2 {
+		t.Errorf("wrong rows: got %v, want %v", got, want)
+	}
+}
+
+func TestRunInTx(t *testing.T) {
+	db, exp, _ := newTracingDB(t)
+	ctx := context.Background()
+
+	_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+		_, err := db.Exec(ctx, "INSERT INTO otel_item (name) VALUES (?);", "tracing-tx")
+		if err != nil {
+			return err
+		}
+		_, _ = db.RunInTx(ctx, func(ctx context.Context) error {
+			return nil
+		})
+		return errors.New("rollback")
+	})
+
+	spans := exp.GetSpans()
+	if got, want := len(spans), 3; got != want {
+		t.Fatalf("wrong spans: got %v, want %v", got, want)
+	}
+
+	exec, sp, tx := spans[0], spans[1], spans[2]
+	if got, want := tx.Name, "sqlxx.tx"; got != want {
+		t.Errorf("wrong name: got %v, want %v", got, want)
+	}
+	if got, want := sp.Name, "sqlxx.savepoint"; got != want {
+		t.Errorf("wrong name: got %v, want %v", got, want)
+	}
+	if got, want := exec.Parent.SpanID(), tx.SpanContext.SpanID(); got != want {
+		t.Errorf("exec: wrong parent: got %v, want %v", got, want)
+	}
+	if got, want := sp.Parent.SpanID(), tx.SpanContext.SpanID(); got != want {
+		t.Errorf("savepoint: wrong parent: got %v, want %v", got, want)
+	}
+	if got, want := spanAttrs(exec)["db.rows_affected"].AsInt64(), int64(1); got != want {
+		t.Errorf("wrong rows affected: got %v, want %v", got, want)
+	}
+	if got, want := spanAttrs(sp)["sqlxx.tx.outcome"].AsString(), "commit"; got != want {
+		t.Errorf("savepoint: wrong outcome: got %v, want %v", got, want)
+	}
+	if got, want := spanAttrs(tx)["sqlxx.tx.outcome"].AsString(), "rollback"; got != want {
+		t.Errorf("tx: wrong outcome: got %v, want %v", got, want)
+	}
+	if got, want := tx.Status.Code, codes.Error; got != want {
+		t.Errorf("tx: wrong status: got %v, want %v", got, want)
+	}
+}
+
+func TestRunInTxWith(t *testing.T) {
+	db, exp, _ := newTracingDB(t)
+
+	readOnly := true
+	opts := &sqlxx.TxOption{ReadOnly: &readOnly}
+	_, _ = db.RunInTxWith(context.Background(), opts, func(ctx context.Context) error { return nil })
+
+	spans := exp.GetSpans()
+	if got, want := len(spans), 1; got != want {
+		t.Fatalf("wrong spans: got %v, want %v", got, want)
+	}
+	attrs := spanAttrs(spans[0])
+	if got, want := attrs["sqlxx.tx.read_only"].AsBool(), true; got != want {
+		t.Errorf("wrong read only: got %v, want %v", got, want)
+	}
+	if got, want := attrs["sqlxx.tx.outcome"].AsString(), "commit"; got != want {
+		t.Errorf("wrong outcome: got %v, want %v", got, want)
+	}
+}
+
+func TestDBSystem(t *testing.T) {
+	tests := []struct {
+		driverName string
+		want       string
+	}{
+		{"mysql", "mysql"},
+		{"pgx", "postgresql"},
+		{"sqlite3", "sqlite"},
+		{"unknown", "other_sql"},
+	}
+
+	for _, tt := range tests {
+		if got := dbSystem(tt.driverName).Value.AsString(); got != tt.want {
+			t.Errorf("%s: want %q, got %q", tt.driverName, tt.want, got)
+		}
+	}
+}
+
+func TestQueryOperation(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM user;", "SELECT"},
+		{"  select 1", "SELECT"},
+		{"\n\tinsert into user values (1)", "INSERT"},
+		{"(SELECT 1) UNION (SELECT 2)", "SELECT"},
+		{"UPDATE user SET email = ''", "UPDATE"},
+		{"delete", "DELETE"},
+		{"", ""},
+		{"/* comment */ SELECT 1", ""},
+	}
+
+	for _, tt := range tests {
+		if got := queryOperation(tt.query); got != tt.want {
+			t.Errorf("%q: want %q, got %q", tt.query, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package sqlxx
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Upsert inserts row, a db-tagged struct, into table, or updates
+// updateColumns of the existing row if the insert conflicts. The statement
+// is INSERT ... ON DUPLICATE KEY UPDATE for MySQL, where conflictColumns are
+// not used because any unique key conflicts, and INSERT ... ON CONFLICT
+// (conflictColumns) DO UPDATE for PostgreSQL and SQLite. If updateColumns is
+// empty, every column but conflictColumns and the primary key (`db:"id,pk"`)
+// is updated. A zero auto-increment primary key is omitted as in Insert. It
+// runs as NamedExec.
+func (db *DB) Upsert(ctx context.Context, table string, row interface{}, conflictColumns, updateColumns []string) (sql.Result, error) {
+	cols, err := db.columns(reflect.TypeOf(row))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := selectColumns(cols, conflictColumns); err != nil {
+		return nil, err
+	}
+	if _, err := selectColumns(cols, updateColumns); err != nil {
+		return nil, err
+	}
+
+	auto := autoIncrementPK(reflect.Indirect(reflect.ValueOf(row)), cols)
+	names := make([]string, 0, len(cols))
+	for _, c := range cols {
+		if auto == nil || c.name != auto.name {
+			names = append(names, c.name)
+		}
+	}
+	pks, _ := splitPK(cols)
+
+	query, err := upsertQuery(db.dbx.DriverName(), table, names, columnNames(pks), conflictColumns, updateColumns)
+	if err != nil {
+		return nil, err
+	}
+	return db.NamedExec(ctx, query, row)
+}
+
+// upsertQuery builds the upsert of cols. If updateColumns is empty, the
+// columns but conflictColumns and pks are updated.
+func upsertQuery(driverName, table string, cols, pks, conflictColumns, updateColumns []string) (string, error) {
+	if len(updateColumns) == 0 {
+		for _, c := range cols {
+			if !contains(conflictColumns, c) && !contains(pks, c) {
+				updateColumns = append(updateColumns, c)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("INSERT INTO " + table + " (" + strings.Join(cols, ", ") + ") VALUES (:" + strings.Join(cols, ", :") + ")")
+
+	switch driverName {
+	case "mysql":
+		b.WriteString(" ON DUPLICATE KEY UPDATE ")
+		if len(updateColumns) == 0 {
+			b.WriteString(cols[0] + " = " + cols[0]) // no-op
+		}
+		for i, c := range updateColumns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(c + " = VALUES(" + c + ")")
+		}
+	case "postgres", "pgx", "pq-timeouts", "cloudsqlpostgres", "sqlite3", "sqlite":
+		if len(conflictColumns) == 0 {
+			return "", xerrors.Errorf("sqlxx: conflict columns required for %s", driverName)
+		}
+		b.WriteString(" ON CONFLICT (" + strings.Join(conflictColumns, ", ") + ")")
+		if len(updateColumns) == 0 {
+			b.WriteString(" DO NOTHING")
+			break
+		}
+		b.WriteString(" DO UPDATE SET ")
+		for i, c := range updateColumns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(c + " = EXCLUDED." + c)
+		}
+	default:
+		return "", xerrors.Errorf("sqlxx: upsert is not supported for %s", driverName)
+	}
+
+	return b.String(), nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
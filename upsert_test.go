@@ -0,0 +1,167 @@
+package sqlxx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpsertQuery(t *testing.T) {
+	cols := []string{"id", "email", "password"}
+
+	tests := []struct {
+		driver   string
+		pks      []string
+		conflict []string
+		update   []string
+		want     string
+	}{
+		{
+			"mysql", nil, []string{"email"}, nil,
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON DUPLICATE KEY UPDATE id = VALUES(id), password = VALUES(password)",
+		},
+		{
+			"mysql", []string{"id"}, []string{"email"}, nil,
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON DUPLICATE KEY UPDATE password = VALUES(password)",
+		},
+		{
+			"mysql", nil, nil, []string{"password"},
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON DUPLICATE KEY UPDATE password = VALUES(password)",
+		},
+		{
+			"mysql", nil, cols, nil,
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON DUPLICATE KEY UPDATE id = id",
+		},
+		{
+			"postgres", nil, []string{"email"}, []string{"password"},
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON CONFLICT (email) DO UPDATE SET password = EXCLUDED.password",
+		},
+		{
+			"pgx", nil, []string{"id"}, nil,
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON CONFLICT (id) DO UPDATE SET email = EXCLUDED.email, password = EXCLUDED.password",
+		},
+		{
+			"sqlite3", nil, []string{"id", "email"}, nil,
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON CONFLICT (id, email) DO UPDATE SET password = EXCLUDED.password",
+		},
+		{
+			"sqlite", nil, cols, nil,
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON CONFLICT (id, email, password) DO NOTHING",
+		},
+		{
+			"sqlite", []string{"id"}, []string{"email", "password"}, nil,
+			"INSERT INTO user (id, email, password) VALUES (:id, :email, :password) ON CONFLICT (email, password) DO NOTHING",
+		},
+	}
+
+	for i, tt := range tests {
+		got, err := upsertQuery(tt.driver, "user", cols, tt.pks, tt.conflict, tt.update)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if got != tt.want {
+			t.Errorf("#%d: want %q, got %q", i, tt.want, got)
+		}
+	}
+
+	if _, err := upsertQuery("postgres", "user", cols, nil, nil, nil); err == nil {
+		t.Error("postgres without conflict columns: want non-nil error")
+	}
+	if _, err := upsertQuery("sqlserver", "user", cols, nil, []string{"id"}, nil); err == nil {
+		t.Error("sqlserver: want non-nil error")
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	type userRow struct {
+		Email    string `db:"email"`
+		Password string `db:"password"`
+	}
+
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil)
+	errRollback := errors.New("rollback")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		if _, err := db.Upsert(ctx, "user", userRow{"upsert@example.com", "before"}, []string{"email"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Upsert(ctx, "user", &userRow{"upsert@example.com", "after"}, []string{"email"}, []string{"password"}); err != nil {
+			t.Fatal(err)
+		}
+
+		var us []User
+		if err := db.Select(ctx, &us, "SELECT id, email, password FROM user WHERE email = ?;", "upsert@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if len(us) != 1 || us[0].Password != "after" {
+			t.Errorf("want 1 updated row, got %v", us)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+
+	if want := "[" + CmdNamedExec + "]"; strings.Count(buf.String(), want) != 2 {
+		t.Errorf("want 2 %s lines, got %q", want, buf.String())
+	}
+	if want := "ON DUPLICATE KEY UPDATE password = VALUES(password) [upsert@example.com, after]"; !strings.Contains(buf.String(), want) {
+		t.Errorf("want %q in the log, got %q", want, buf.String())
+	}
+
+	ctx := context.Background()
+	if _, err := db.Upsert(ctx, "user", userRow{}, []string{"name"}, nil); err == nil {
+		t.Error("unknown conflict column: want non-nil error")
+	}
+	if _, err := db.Upsert(ctx, "user", userRow{}, nil, []string{"name"}); err == nil {
+		t.Error("unknown update column: want non-nil error")
+	}
+	if _, err := db.Upsert(ctx, "user", "row", nil, nil); err == nil {
+		t.Error("not struct: want non-nil error")
+	}
+}
+
+func TestUpsertKeepsPK(t *testing.T) {
+	var buf bytes.Buffer
+	db := New(dbx, NewLogger(&buf), nil)
+	errRollback := errors.New("rollback")
+
+	err, _ := db.RunInTx(context.Background(), func(ctx context.Context) error {
+		for _, password := range []string{"before", "after"} {
+			u := crudUser{Email: "upsert-pk@example.com", Password: password}
+			if _, err := db.Upsert(ctx, "user", u, []string{"email"}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var before crudUser
+		if err := db.Get(ctx, &before, "SELECT id, email, password FROM user WHERE email = ?", "upsert-pk@example.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		u := crudUser{Email: "upsert-pk@example.com", Password: "again"}
+		if _, err := db.Upsert(ctx, "user", u, []string{"email"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		var after crudUser
+		if err := db.Get(ctx, &after, "SELECT id, email, password FROM user WHERE email = ?", "upsert-pk@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if after.ID != before.ID {
+			t.Errorf("want id %d unchanged, got %d", before.ID, after.ID)
+		}
+		if after.Password != "again" {
+			t.Errorf("want again, got %v", after.Password)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+
+	if want := "INSERT INTO user (email, password) VALUES (:email, :password) ON DUPLICATE KEY UPDATE password = VALUES(password) ["; !strings.Contains(buf.String(), want) {
+		t.Errorf("want %q in the log, got %q", want, buf.String())
+	}
+}